@@ -2,8 +2,14 @@ package aoebot
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"math/rand"
 	"os"
+	"strings"
+	"time"
 )
 
 // Action can be performed given the environment of its trigger
@@ -16,9 +22,11 @@ type Action interface {
 type ActionType string
 
 const (
-	write ActionType = "write"
-	voice ActionType = "voice"
-	react ActionType = "react"
+	write    ActionType = "write"
+	voice    ActionType = "voice"
+	react    ActionType = "react"
+	random   ActionType = "random"
+	sequence ActionType = "sequence"
 )
 
 // WriteAction specifies content that can be written to a text channel
@@ -27,8 +35,13 @@ type WriteAction struct {
 	TTS     bool
 }
 
+// Content is rendered as a template of the environment, see ParseTemplate
 func (wa WriteAction) Perform(env *Environment) error {
-	return env.Bot.Write(env.TextChannel.ID, wa.Content, wa.TTS)
+	content, err := wa.render(env)
+	if err != nil {
+		return err
+	}
+	return env.Bot.Write(env.TextChannel.ID, content, wa.TTS)
 }
 
 func (wa WriteAction) kind() ActionType {
@@ -61,9 +74,11 @@ func (ra ReactAction) String() string {
 }
 
 // VoiceAction specifies audio that can be said to a voice channel
+// The audio is an uploaded clip when Clip is set, otherwise it is File
 type VoiceAction struct {
 	File  string `bson:"file,omitempty"`
 	Alias string `bson:"alias,omitempty"`
+	Clip  string `bson:"clip,omitempty"`
 }
 
 func (va VoiceAction) Perform(env *Environment) error {
@@ -72,13 +87,23 @@ func (va VoiceAction) Perform(env *Environment) error {
 		return err
 	}
 	if env.VoiceChannel != nil {
-		return env.Bot.Say(env.Guild.ID, env.VoiceChannel.ID, r)
+		log.Printf("Say %v to voice channel %v", va.Path(), env.VoiceChannel.Name)
+		return env.Bot.Say(env.Guild.ID, env.VoiceChannel.ID, va.String(), r)
+	}
+	log.Printf("Say %v to %s", va.Path(), env.Author)
+	return env.Bot.sayToUserInGuild(env.Guild, env.Author.ID, va.String(), r)
+}
+
+// Path is the file of the audio
+func (va VoiceAction) Path() string {
+	if va.Clip != "" {
+		return ClipFile(va.Clip)
 	}
-	return env.Bot.sayToUserInGuild(env.Guild, env.Author.ID, r)
+	return va.File
 }
 
 func (va VoiceAction) load() (io.Reader, error) {
-	file, err := os.Open(va.File)
+	file, err := os.Open(va.Path())
 	if err != nil {
 		return nil, err
 	}
@@ -97,5 +122,104 @@ func (va VoiceAction) String() string {
 	if va.Alias != "" {
 		return va.Alias
 	}
-	return va.File
+	return va.Path()
+}
+
+// RandomAction specifies a set of actions where only one is performed at a time
+// Actions with a greater weight are more likely to be performed
+type RandomAction struct {
+	Choices []WeightedAction
+}
+
+// WeightedAction is a choice of a RandomAction
+type WeightedAction struct {
+	Weight int
+	Action ActionEnvelope
+}
+
+func (ra RandomAction) Perform(env *Environment) error {
+	if len(ra.Choices) == 0 {
+		return errors.New("No choices")
+	}
+	total := 0
+	for _, choice := range ra.Choices {
+		total += choice.weight()
+	}
+	n := rand.Intn(total)
+	for _, choice := range ra.Choices {
+		n -= choice.weight()
+		if n < 0 {
+			return choice.Action.Perform(env)
+		}
+	}
+	return nil
+}
+
+func (ra RandomAction) kind() ActionType {
+	return random
+}
+
+func (ra RandomAction) String() string {
+	choices := make([]string, len(ra.Choices))
+	for i, choice := range ra.Choices {
+		choices[i] = fmt.Sprintf("%s", choice.Action.Action)
+	}
+	return strings.Join(choices, " | ")
+}
+
+// weights less than 1 are treated as 1
+func (wa WeightedAction) weight() int {
+	if wa.Weight < 1 {
+		return 1
+	}
+	return wa.Weight
+}
+
+// SequenceAction specifies actions that are performed one after another
+// Each step can wait for a delay before it is performed
+type SequenceAction struct {
+	Steps []SequenceStep
+}
+
+// SequenceStep is a step of a SequenceAction
+type SequenceStep struct {
+	Delay  time.Duration
+	Action ActionEnvelope
+}
+
+// Perform performs every step even if an earlier step fails, and returns the first error
+// Perform stops early if the environment's context is done, e.g. the bot stops
+func (sa SequenceAction) Perform(env *Environment) error {
+	var err error
+	for _, step := range sa.Steps {
+		if step.Delay > 0 {
+			select {
+			case <-time.After(step.Delay):
+			case <-env.done():
+				return errors.New("Stopped before finishing sequence")
+			}
+		}
+		if stepErr := step.Action.Perform(env); stepErr != nil && err == nil {
+			err = stepErr
+		}
+	}
+	return err
+}
+
+func (sa SequenceAction) kind() ActionType {
+	return sequence
+}
+
+func (sa SequenceAction) String() string {
+	buf := &bytes.Buffer{}
+	for i, step := range sa.Steps {
+		if i > 0 {
+			buf.WriteString(" then ")
+		}
+		if step.Delay > 0 {
+			fmt.Fprintf(buf, "after %s ", step.Delay)
+		}
+		fmt.Fprintf(buf, "%s", step.Action.Action)
+	}
+	return buf.String()
 }
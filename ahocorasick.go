@@ -0,0 +1,95 @@
+package aoebot
+
+// ahoCorasick finds every occurrence of a set of patterns in a single pass over some text
+// https://en.wikipedia.org/wiki/Aho%E2%80%93Corasick_algorithm
+type ahoCorasick struct {
+	nodes    []acNode
+	patterns []string
+}
+
+type acNode struct {
+	next map[byte]int
+	fail int
+	// patterns that end at this node, including those found by following fail links
+	output []int
+}
+
+// acMatch is an occurrence of patterns[pattern] at text[start:end]
+type acMatch struct {
+	pattern int
+	start   int
+	end     int
+}
+
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	ac := &ahoCorasick{
+		nodes:    []acNode{{next: make(map[byte]int)}},
+		patterns: patterns,
+	}
+
+	// build a trie of the patterns
+	for i, p := range patterns {
+		state := 0
+		for j := 0; j < len(p); j++ {
+			next, ok := ac.nodes[state].next[p[j]]
+			if !ok {
+				ac.nodes = append(ac.nodes, acNode{next: make(map[byte]int)})
+				next = len(ac.nodes) - 1
+				ac.nodes[state].next[p[j]] = next
+			}
+			state = next
+		}
+		ac.nodes[state].output = append(ac.nodes[state].output, i)
+	}
+
+	// breadth first so the fail link of a node is complete before its children are visited
+	queue := []int{}
+	for _, child := range ac.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for b, child := range ac.nodes[state].next {
+			queue = append(queue, child)
+			fail := ac.nodes[state].fail
+			for fail != 0 {
+				if _, ok := ac.nodes[fail].next[b]; ok {
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+			if next, ok := ac.nodes[fail].next[b]; ok && next != child {
+				ac.nodes[child].fail = next
+			}
+			failOutput := ac.nodes[ac.nodes[child].fail].output
+			ac.nodes[child].output = append(ac.nodes[child].output, failOutput...)
+		}
+	}
+	return ac
+}
+
+// findAll returns every occurrence of every pattern in text, including overlapping occurrences
+func (ac *ahoCorasick) findAll(text string) []acMatch {
+	matches := []acMatch{}
+	state := 0
+	for i := 0; i < len(text); i++ {
+		for state != 0 {
+			if _, ok := ac.nodes[state].next[text[i]]; ok {
+				break
+			}
+			state = ac.nodes[state].fail
+		}
+		if next, ok := ac.nodes[state].next[text[i]]; ok {
+			state = next
+		}
+		for _, p := range ac.nodes[state].output {
+			matches = append(matches, acMatch{
+				pattern: p,
+				start:   i + 1 - len(ac.patterns[p]),
+				end:     i + 1,
+			})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,53 @@
+package aoebot
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestAhoCorasick(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		text     string
+	}{
+		{[]string{"he", "she", "his", "hers"}, "ushers"},
+		{[]string{"a", "aa", "aaa"}, "aaaa"},
+		{[]string{"ping", "pong"}, "ping pong pingpong"},
+		{[]string{"abcd", "bc", "c"}, "abcabcd"},
+		{[]string{"butt"}, "no match here"},
+		{[]string{"gg", "wp"}, ""},
+	}
+	for _, tt := range tests {
+		got := newAhoCorasick(tt.patterns).findAll(tt.text)
+		want := naiveFindAll(tt.patterns, tt.text)
+		sortMatches(got)
+		sortMatches(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("findAll(%q) with %q = %v, want %v", tt.text, tt.patterns, got, want)
+		}
+	}
+}
+
+// naiveFindAll looks for each pattern at each position of text
+func naiveFindAll(patterns []string, text string) []acMatch {
+	matches := []acMatch{}
+	for p, pattern := range patterns {
+		for i := 0; i+len(pattern) <= len(text); i++ {
+			if strings.HasPrefix(text[i:], pattern) {
+				matches = append(matches, acMatch{pattern: p, start: i, end: i + len(pattern)})
+			}
+		}
+	}
+	return matches
+}
+
+func sortMatches(matches []acMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].pattern < matches[j].pattern
+	})
+}
@@ -0,0 +1,408 @@
+package aoebot
+
+import (
+	"encoding/binary"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	conditionsBucket = []byte("conditions")
+	channelsBucket   = []byte("channels")
+	guildsBucket     = []byte("guilds")
+	gamesBucket      = []byte("games")
+	clipsBucket      = []byte("clips")
+)
+
+// boltStore is a Store backed by a single bolt database file
+// Documents are encoded as bson so they have the same shape as they would in MongoDB
+type boltStore struct {
+	db *bolt.DB
+}
+
+// DialBolt opens a bolt database file, creating it if it does not exist
+func DialBolt(path string) StoreDialer {
+	return func() (Store, error) {
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+		if err != nil {
+			return nil, err
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{conditionsBucket, channelsBucket, guildsBucket, gamesBucket, clipsBucket} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &boltStore{db}, nil
+	}
+}
+
+// conditions retrieves every condition that passes a filter
+func (s *boltStore) conditions(filter func(c Condition) bool) []Condition {
+	conditions, err := s.scanConditions(filter)
+	if err != nil {
+		log.Printf("Error in query conditions %v", err)
+	}
+	return conditions
+}
+
+func (s *boltStore) scanConditions(filter func(c Condition) bool) ([]Condition, error) {
+	conditions := []Condition{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(conditionsBucket).ForEach(func(k, v []byte) error {
+			var c Condition
+			if err := bson.Unmarshal(v, &c); err != nil {
+				return err
+			}
+			if filter(c) {
+				conditions = append(conditions, c)
+			}
+			return nil
+		})
+	})
+	return conditions, err
+}
+
+func (s *boltStore) ConditionsEnabled() ([]Condition, error) {
+	return s.scanConditions(func(c Condition) bool {
+		return c.IsEnabled
+	})
+}
+
+func (s *boltStore) ConditionsEnvironment(env *Environment) []Condition {
+	return s.conditions(func(c Condition) bool {
+		c.RegexPhrase = ""
+		return c.IsEnabled && env.Satisfies(c)
+	})
+}
+
+func (s *boltStore) ConditionsGuild(guildID string) []Condition {
+	conditions := s.conditions(func(c Condition) bool {
+		return c.CreatedBy != "" && c.GuildID == guildID && c.IsEnabled
+	})
+	log.Printf("Found %v custom conditions for guild", len(conditions))
+	return conditions
+}
+
+func (s *boltStore) ConditionsTagged(tag string) []Condition {
+	return s.conditions(func(c Condition) bool {
+		for _, t := range c.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// updateConditions applies an update to every condition that matches a selector
+// updateConditions applies the update to a new document when it is an upsert and no conditions match
+func (s *boltStore) updateConditions(selector *Condition, update func(c *Condition), upsert bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(conditionsBucket)
+		matched := 0
+		err := b.ForEach(func(k, v []byte) error {
+			if upsert && matched > 0 {
+				return nil
+			}
+			ok, err := bsonSubset(selector, v)
+			if err != nil || !ok {
+				return err
+			}
+			var c Condition
+			if err := bson.Unmarshal(v, &c); err != nil {
+				return err
+			}
+			update(&c)
+			buf, err := bson.Marshal(c)
+			if err != nil {
+				return err
+			}
+			matched++
+			return b.Put(k, buf)
+		})
+		if err != nil || matched > 0 || !upsert {
+			return err
+		}
+
+		c := *selector
+		update(&c)
+		buf, err := bson.Marshal(c)
+		if err != nil {
+			return err
+		}
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(id), buf)
+	})
+}
+
+func (s *boltStore) ConditionAdd(c *Condition, creator string) error {
+	selector := c.identity()
+	err := s.updateConditions(&selector, func(match *Condition) {
+		match.Name = c.GeneratedName()
+		match.CreatedBy = creator
+		match.IsEnabled = true
+		match.Cooldown = c.Cooldown
+		match.CooldownScope = c.CooldownScope
+		match.Chance = c.Chance
+	}, true)
+	if err == nil {
+		log.Printf("added Condition %v", c.GeneratedName())
+	}
+	return err
+}
+
+func (s *boltStore) ConditionDisable(c *Condition) error {
+	err := s.updateConditions(c, func(match *Condition) {
+		match.IsEnabled = false
+	}, false)
+	if err == nil {
+		log.Printf("disabled Condition %v", c.GeneratedName())
+	}
+	return err
+}
+
+func (s *boltStore) ConditionsSwapClip(guildID string, oldID string, newID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(conditionsBucket)
+		return b.ForEach(func(k, v []byte) error {
+			var c Condition
+			if err := bson.Unmarshal(v, &c); err != nil {
+				return err
+			}
+			if c.CreatedBy == "" || c.GuildID != guildID || !c.IsEnabled || !swapClip(&c, oldID, newID) {
+				return nil
+			}
+			buf, err := bson.Marshal(c)
+			if err != nil {
+				return err
+			}
+			return b.Put(k, buf)
+		})
+	})
+}
+
+func (s *boltStore) channels(filter func(ch ManagedChannel) bool) []ManagedChannel {
+	channels := []ManagedChannel{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(channelsBucket).ForEach(func(k, v []byte) error {
+			var ch ManagedChannel
+			if err := bson.Unmarshal(v, &ch); err != nil {
+				return err
+			}
+			if filter(ch) {
+				channels = append(channels, ch)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Error in query managed channels %v", err)
+	}
+	return channels
+}
+
+func (s *boltStore) Channels() []ManagedChannel {
+	return s.channels(func(ch ManagedChannel) bool {
+		return true
+	})
+}
+
+func (s *boltStore) ChannelsGuild(guildID string) []ManagedChannel {
+	return s.channels(func(ch ManagedChannel) bool {
+		return ch.Channel != nil && ch.Channel.GuildID == guildID
+	})
+}
+
+func (s *boltStore) ChannelAdd(ch ManagedChannel) error {
+	buf, err := bson.Marshal(ch)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(channelsBucket).Put([]byte(ch.Channel.ID), buf)
+	})
+}
+
+func (s *boltStore) ChannelDelete(channelID ...string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(channelsBucket)
+		for _, id := range channelID {
+			if err := b.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) GuildPrefs(guildID string) (*GuildPrefs, error) {
+	prefs := &GuildPrefs{GuildID: guildID}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(guildsBucket).Get([]byte(guildID))
+		if v == nil {
+			return ErrNotFound
+		}
+		return bson.Unmarshal(v, prefs)
+	})
+	return prefs, err
+}
+
+func (s *boltStore) GuildPrefsSet(prefs *GuildPrefs) error {
+	buf, err := bson.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(guildsBucket).Put([]byte(prefs.GuildID), buf)
+	})
+	if err == nil {
+		log.Printf("set guild prefs %v", prefs.GuildID)
+	}
+	return err
+}
+
+func (s *boltStore) GameByAlias(alias string) string {
+	ga := GameAlias{}
+	s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(gamesBucket).Get([]byte(strings.ToLower(alias)))
+		if v == nil {
+			return nil
+		}
+		return bson.Unmarshal(v, &ga)
+	})
+	return ga.Game
+}
+
+func (s *boltStore) GameAliasAdd(ga GameAlias) error {
+	buf, err := bson.Marshal(ga)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(gamesBucket).Put([]byte(ga.Alias), buf)
+	})
+}
+
+func (s *boltStore) Games() (games []string) {
+	unique := make(map[string]struct{})
+	s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(gamesBucket).ForEach(func(k, v []byte) error {
+			ga := GameAlias{}
+			if err := bson.Unmarshal(v, &ga); err != nil {
+				return err
+			}
+			unique[ga.Game] = struct{}{}
+			return nil
+		})
+	})
+	for game := range unique {
+		games = append(games, game)
+	}
+	sort.Strings(games)
+	return
+}
+
+func (s *boltStore) Clip(id string) (*Clip, error) {
+	clip := &Clip{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(clipsBucket).Get([]byte(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		return bson.Unmarshal(v, clip)
+	})
+	return clip, err
+}
+
+func (s *boltStore) Clips() []Clip {
+	clips := []Clip{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(clipsBucket).ForEach(func(k, v []byte) error {
+			var clip Clip
+			if err := bson.Unmarshal(v, &clip); err != nil {
+				return err
+			}
+			clips = append(clips, clip)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Error in query clips %v", err)
+	}
+	return clips
+}
+
+func (s *boltStore) ClipAdd(clip *Clip) error {
+	buf, err := bson.Marshal(clip)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(clipsBucket)
+		if b.Get([]byte(clip.ID)) != nil {
+			return nil
+		}
+		return b.Put([]byte(clip.ID), buf)
+	})
+}
+
+func (s *boltStore) ClipDelete(clipID ...string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(clipsBucket)
+		for _, id := range clipID {
+			if err := b.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Close() {
+	s.db.Close()
+}
+
+// bsonSubset is true when every field in the bson encoding of selector has an equal field in doc
+// This mirrors how MongoDB treats a struct used as a query selector
+func bsonSubset(selector interface{}, doc []byte) (bool, error) {
+	buf, err := bson.Marshal(selector)
+	if err != nil {
+		return false, err
+	}
+	sel := bson.M{}
+	if err := bson.Unmarshal(buf, sel); err != nil {
+		return false, err
+	}
+	d := bson.M{}
+	if err := bson.Unmarshal(doc, d); err != nil {
+		return false, err
+	}
+	for k, v := range sel {
+		if !reflect.DeepEqual(d[k], v) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -1,9 +1,11 @@
 package aoebot
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"os"
 	"strings"
 	"sync"
@@ -20,6 +22,8 @@ type Config struct {
 	MaxManagedVoiceDuration    int    `toml:"max_managed_voice_duration"`
 	MaxManagedChannels         int    `toml:"max_managed_channels"`
 	ManagedChannelPollInterval int    `toml:"managed_channel_poll_interval"`
+	CommandWorkers             int    `toml:"command_workers"`
+	CommandTimeout             int    `toml:"command_timeout"`
 	Voice                      dgv.PlayerConfig
 }
 
@@ -29,6 +33,8 @@ var DefaultConfig = Config{
 	MaxManagedVoiceDuration:    5,
 	MaxManagedChannels:         5,
 	ManagedChannelPollInterval: 60,
+	CommandWorkers:             4,
+	CommandTimeout:             30,
 	Voice: dgv.PlayerConfig{
 		QueueLength: 100,
 		SendTimeout: 1000,
@@ -40,41 +46,56 @@ var DefaultConfig = Config{
 type Bot struct {
 	mu         sync.Mutex // TODO synchronize state and use of maps
 	Config     Config
-	mongo      string
+	dial       StoreDialer
 	owner      string
 	signalCh   chan<- os.Signal
 	commands   []Command
 	Driver     *Driver
-	Session    *discordgo.Session
+	Session    Session
+	State      *discordgo.State
 	self       *discordgo.User
+	appID      string
+	registered *registeredCommands
 	routines   map[*func()]struct{}   // Set
 	unhandlers map[*func()]struct{}   // Set
 	voiceboxes map[string]*dgv.Player // TODO voiceboxes is vulnerable to concurrent read/write
-	occupancy  map[string]string      // TODO occupancy is vulnerable to concurrent read/write
+	occupancy  *occupancy
+	cooldowns  *cooldowns
+	queues     *voiceQueues
+	ctx        context.Context
+	jobs       chan job
+	pending    sync.WaitGroup // commands and actions that have not finished
+	cancel     context.CancelFunc
 	aesthetic  bool
 }
 
 // New initializes a bot
-func New(token string, mongo string, owner string, signalCh chan<- os.Signal) (b *Bot, err error) {
+// The bot opens its Store using dial whenever it starts
+func New(token string, dial StoreDialer, owner string, signalCh chan<- os.Signal) (b *Bot, err error) {
 	b = &Bot{
 		Config:     DefaultConfig,
-		mongo:      mongo,
+		dial:       dial,
 		owner:      owner,
 		signalCh:   signalCh,
 		routines:   make(map[*func()]struct{}),
 		unhandlers: make(map[*func()]struct{}),
 		voiceboxes: make(map[string]*dgv.Player),
-		occupancy:  make(map[string]string),
+		occupancy:  newOccupancy(),
+		cooldowns:  newCooldowns(),
+		queues:     newVoiceQueues(),
+		registered: newRegisteredCommands(),
 	}
-	b.Session, err = discordgo.New("Bot " + token)
+	session, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return
 	}
+	b.Session, b.State = session, session.State
 	b.commands = []Command{
 		&Help{},
 		&Reconnect{},
 		&Restart{},
 		&Shutdown{},
+		&Perms{},
 	}
 	return
 }
@@ -86,6 +107,14 @@ func (b *Bot) WithConfig(cfg Config) {
 	b.Config = cfg
 }
 
+// WithSession replaces the discord session and the state that guilds, channels, etc. are read from
+// WithSession should be used before the bot starts
+func (b *Bot) WithSession(s Session, state *discordgo.State) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Session, b.State = s, state
+}
+
 // AddCommand commands are ordered
 func (b *Bot) AddCommand(c ...Command) {
 	b.mu.Lock()
@@ -93,20 +122,27 @@ func (b *Bot) AddCommand(c ...Command) {
 	b.commands = append(b.commands, c...)
 }
 
-// Start initiates a database session and a discord session
+// Start opens the bot's Store and initiates a discord session
 func (b *Bot) Start() (err error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.Driver, err = newDriver(b.mongo)
+	b.Driver, err = newDriver(b.dial)
 	if err != nil {
 		return
 	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	b.startWorkers(b.ctx)
 
 	b.self, err = b.Session.User("@me")
 	if err != nil {
 		return
 	}
+	// slash commands are optional, the bot still answers commands written in messages without them
+	if b.appID, err = b.applicationID(); err != nil {
+		log.Printf("Error looking up application id, running without slash commands: %v", err)
+		err = nil
+	}
 
 	b.addHandlerOnce(b.onReady())
 
@@ -119,12 +155,18 @@ func (b *Bot) Start() (err error) {
 	return
 }
 
-// Stop removes event handlers, stops all workers, closes the discord session, and closes the db session.
+// Stop removes event handlers, stops all workers, closes the discord session, and closes the Store.
 func (b *Bot) Stop() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	log.Printf("Closing session...")
 
+	// interrupt any actions that are still waiting to be performed and any commands that are running
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.jobs = nil
+
 	log.Printf("Disabling event handlers...")
 	for f := range b.unhandlers {
 		if f != nil {
@@ -145,13 +187,14 @@ func (b *Bot) Stop() {
 	for k, player := range b.voiceboxes {
 		player.Quit()
 		delete(b.voiceboxes, k)
+		b.queues.reset(k)
 	}
 
 	// close the session after closing voice boxes since closing voiceboxes attempts graceful voiceconnection disconnect using discord session
 	log.Printf("Closing discord...")
 	b.Session.Close()
 
-	log.Printf("Closing mongo...")
+	log.Printf("Closing store...")
 	b.Driver.Close()
 
 	log.Printf("...closed session.")
@@ -184,9 +227,14 @@ func (b *Bot) React(channelID string, messageID string, emoji string) (unreact f
 
 // Say some audio frames to a channel in a guild
 // Say drops the payload when the voicebox for that guild queue is full
-func (b *Bot) Say(guildID string, channelID string, reader io.Reader) (err error) {
+// The title lists the payload in the guild's queue
+func (b *Bot) Say(guildID string, channelID string, title string, reader io.Reader) (err error) {
 	if player, ok := b.voiceboxes[guildID]; ok && player != nil {
-		err = player.Enqueue(channelID, "", dgv.PreEncoded(reader))
+		item := &QueueItem{Title: title, ChannelID: channelID}
+		err = player.Enqueue(channelID, title, dgv.PreEncoded(b.queues.add(guildID, item, reader)))
+		if err != nil {
+			b.queues.remove(guildID, item)
+		}
 	} else {
 		err = fmt.Errorf("No voicebox registered for guild %v", guildID)
 	}
@@ -194,16 +242,21 @@ func (b *Bot) Say(guildID string, channelID string, reader io.Reader) (err error
 }
 
 // helper func
-func (b *Bot) sayToUserInGuild(guild *discordgo.Guild, userID string, reader io.Reader) (err error) {
+func (b *Bot) sayToUserInGuild(guild *discordgo.Guild, userID string, title string, reader io.Reader) (err error) {
 	for _, vs := range guild.VoiceStates {
 		if vs.UserID == userID {
-			return b.Say(guild.ID, vs.ChannelID, reader)
+			return b.Say(guild.ID, vs.ChannelID, title, reader)
 		}
 	}
 	err = fmt.Errorf("Couldn't find user %v in a voice channel in guild %v", userID, guild.ID)
 	return
 }
 
+// SayToUser says some audio frames to the voice channel a user is connected to in a guild
+func (b *Bot) SayToUser(guild *discordgo.Guild, userID string, title string, reader io.Reader) error {
+	return b.sayToUserInGuild(guild, userID, title, reader)
+}
+
 // Add an event handler to the discord session and retain a reference to the handler remover
 func (b *Bot) addHandler(handler interface{}) {
 	unhandler := b.Session.AddHandler(handler)
@@ -216,6 +269,21 @@ func (b *Bot) addHandlerOnce(handler interface{}) {
 	b.unhandlers[&unhandler] = struct{}{}
 }
 
+// context is done when the bot stops
+func (b *Bot) context() context.Context {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
+// Mention is how to mention the bot in a message
+func (b *Bot) Mention() string {
+	return b.self.Mention()
+}
+
 // IsOwnEnvironment is true when an environment's seed is the result of the bot's own actions/behavior
 // This is useful to prevent the bot from reacting to itself
 func (b *Bot) IsOwnEnvironment(env *Environment) bool {
@@ -244,21 +312,21 @@ func (b *Bot) AddRoutine(f func(<-chan struct{})) func() {
 	return closer
 }
 
-// channel's fields must be exported to be visible to bson.Marshal
+// ManagedChannel is a voice channel made by AddManagedVoiceChannel, which is deleted once it is empty
+// ManagedChannel's fields must be exported to be visible to bson.Marshal
 // currently only needs ID and GuildID from *discordgo.Channel, but may be convenient to just take everything
-// channel itself does not need to be exported
-type channel struct {
+type ManagedChannel struct {
 	IsOpen  bool
 	Users   int
 	Channel *discordgo.Channel
 }
 
 // ChannelOption is a functional option used as a variadic parameter to AddManagedVoiceChannel
-type ChannelOption func(*channel)
+type ChannelOption func(*ManagedChannel)
 
 // ChannelOpenMic sets whether the discord channel will override the UserVoiceActivity permission
 func ChannelOpenMic(b bool) ChannelOption {
-	return func(ch *channel) {
+	return func(ch *ManagedChannel) {
 		ch.IsOpen = b
 	}
 }
@@ -266,7 +334,7 @@ func ChannelOpenMic(b bool) ChannelOption {
 // ChannelUsers sets whether the discord channel will have a user limit
 // values for n that are less than 1 or greater than 99 will have no effect
 func ChannelUsers(n int) ChannelOption {
-	return func(ch *channel) {
+	return func(ch *ManagedChannel) {
 		if 0 < n && n < 100 {
 			ch.Users = n
 		}
@@ -276,7 +344,7 @@ func ChannelUsers(n int) ChannelOption {
 // AddManagedVoiceChannel creates a new voice channel in a guild
 // The voice channel will be polled periodically and deleted if it is found to be empty
 func (b *Bot) AddManagedVoiceChannel(guildID string, name string, options ...ChannelOption) (err error) {
-	var ch channel
+	var ch ManagedChannel
 	for _, opt := range options {
 		opt(&ch)
 	}
@@ -287,7 +355,7 @@ func (b *Bot) AddManagedVoiceChannel(guildID string, name string, options ...Cha
 	}
 	log.Printf("created new discord channel %#v", ch.Channel)
 
-	delete := func(ch channel) {
+	delete := func(ch ManagedChannel) {
 		log.Printf("Deleting channel %v", ch.Channel.Name)
 		b.Session.ChannelDelete(ch.Channel.ID)
 		b.Driver.ChannelDelete(ch.Channel.ID)
@@ -298,8 +366,8 @@ func (b *Bot) AddManagedVoiceChannel(guildID string, name string, options ...Cha
 		return
 	}
 
-	isEmpty := func(ch channel) bool {
-		g, err := b.Session.State.Guild(ch.Channel.GuildID)
+	isEmpty := func(ch ManagedChannel) bool {
+		g, err := b.State.Guild(ch.Channel.GuildID)
 		if err == nil {
 			for _, v := range g.VoiceStates {
 				if v.ChannelID == ch.Channel.ID {
@@ -336,7 +404,7 @@ func (b *Bot) AddManagedVoiceChannel(guildID string, name string, options ...Cha
 // channelManager returns a botroutine that periodically polls a channel and deletes it if its empty
 // isEmpty must return true if the channel is already deleted
 // delete should not panic if the channel is already deleted
-func channelManager(ch channel, delete func(ch channel), isEmpty func(ch channel) bool, pollInterval time.Duration) botroutine {
+func channelManager(ch ManagedChannel, delete func(ch ManagedChannel), isEmpty func(ch ManagedChannel) bool, pollInterval time.Duration) botroutine {
 	return func(quit <-chan struct{}) {
 		for {
 			select {
@@ -357,24 +425,55 @@ func (b *Bot) speakTo(g *discordgo.Guild) {
 	player, ok := b.voiceboxes[g.ID]
 	if ok {
 		player.Quit()
+		delete(b.voiceboxes, g.ID)
+	}
+	b.queues.reset(g.ID)
+	// voice connections need a real discord session
+	session, ok := b.Session.(*discordgo.Session)
+	if !ok {
+		return
 	}
 	ql := dgv.QueueLength(b.Config.Voice.QueueLength)
 	st := dgv.SendTimeout(b.Config.Voice.SendTimeout)
 	at := dgv.IdleTimeout(b.Config.Voice.IdleTimeout)
-	b.voiceboxes[g.ID] = dgv.Connect(b.Session, g.ID, g.AfkChannelID, ql, st, at)
+	b.voiceboxes[g.ID] = dgv.Connect(session, g.ID, g.AfkChannelID, ql, st, at)
 }
 
-func (b *Bot) command(args []string) (Command, []string) {
-	if len(args) > 0 {
-		candidate := strings.ToLower(args[0])
-		args := args[1:]
-		for _, cmd := range b.commands {
-			if matchesNameOrAlias(cmd, candidate) {
-				return cmd, args
-			}
+// Prefix is the command prefix of a guild, or the default prefix when the guild has not saved one
+func (b *Bot) Prefix(guildID string) string {
+	if guildID != "" {
+		if prefix := b.Driver.Prefix(guildID); prefix != "" {
+			return prefix
+		}
+	}
+	return b.Config.Prefix
+}
+
+// trimPrefix removes the command prefix of the environment's guild or a mention of the bot from the start of a message
+// trimPrefix is false when the message is not a command
+func (b *Bot) trimPrefix(env *Environment) (string, bool) {
+	content := env.TextMessage.Content
+	prefixes := []string{
+		env.Prefix(),
+		"<@" + b.self.ID + ">",
+		"<@!" + b.self.ID + ">",
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(content, prefix) {
+			return strings.TrimPrefix(content, prefix), true
 		}
 	}
-	return &Help{}, []string{}
+	return "", false
+}
+
+// command finds the command named by the leading arguments
+// command returns the path of groups to the command and the command's arguments
+// The path is empty when the first argument is not the name of a command
+func (b *Bot) command(args []string) ([]Command, []string) {
+	if len(args) == 0 {
+		return []Command{&Help{}}, []string{}
+	}
+	return resolve(b.commands, args)
 }
 
 func matchesNameOrAlias(cmd Command, candidate string) bool {
@@ -389,31 +488,65 @@ func matchesNameOrAlias(cmd Command, candidate string) bool {
 	return false
 }
 
-func (b *Bot) exec(env *Environment, cmd Command, args []string) {
-	if cmd.IsOwnerOnly() && env.Author.ID != b.owner {
-		b.Write(env.TextChannel.ID, "I'm sorry, Dave.  I'm afraid I can't do that.  🔴", false)
+// exec queues a command to run on a worker
+func (b *Bot) exec(env *Environment, path []Command, args []string) {
+	if !b.permissions(env).allow(path) {
+		env.Reply("I'm sorry, Dave.  I'm afraid I can't do that.  🔴")
 		return
 	}
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("Recovered from panic in exec %v with %v: %v", cmd.Name(), args, err)
-		}
-	}()
+	ok := b.enqueue(func(ctx context.Context) {
+		b.execute(ctx, env, path, args)
+	})
+	if !ok {
+		log.Printf("Drop exec %v with %v: too busy", pathName(path), args)
+		env.Reply("I'm too busy right now, try again in a moment 😓")
+	}
+}
 
-	err := cmd.Run(env, args)
+// run parses the input of an ArgsCommand against its schema before running it
+func run(env *Environment, path []Command, args []string) error {
+	cmd := path[len(path)-1]
+	if g, ok := cmd.(*Group); ok {
+		if len(args) > 0 {
+			return unknownCommand(env, g.Subcommands, path, args[0])
+		}
+		return fmt.Errorf("Try %v %v", env.Prefix(), pathUsage(path))
+	}
+	ac, ok := cmd.(ArgsCommand)
+	if !ok {
+		return cmd.Run(env, args)
+	}
+	parsed, err := ac.Schema().Parse(env, args)
 	if err != nil {
-		log.Printf("Error in exec %v with %v: %v", cmd.Name(), args, err)
-		b.Write(env.TextChannel.ID, fmt.Sprintf("🤔...\n%v", err), false)
-	} else if cmd.Ack(env) != "" {
-		b.React(env.TextChannel.ID, env.TextMessage.ID, cmd.Ack(env))
+		return fmt.Errorf("%v\nUsage: %v %v", err, env.Prefix(), pathUsage(path))
 	}
+	return ac.RunArgs(env, parsed)
 }
 
-func (b *Bot) dispatch(env *Environment, actions ...Action) {
-	for _, a := range actions {
+// dispatch performs the actions of conditions that are not cooling down
+// conditions with a chance are only dispatched that percent of the time
+func (b *Bot) dispatch(env *Environment, matches ...match) {
+	for _, m := range matches {
+		c := m.Condition
+		if 0 < c.Chance && c.Chance < 100 && rand.Intn(100) >= c.Chance {
+			log.Printf("Skip %v on %v: unlucky", c.GeneratedName(), env.Type)
+			continue
+		}
+		if !b.cooldowns.take(env, c) {
+			log.Printf("Skip %v on %v: cooling down", c.GeneratedName(), env.Type)
+			continue
+		}
+		// each action sees the captures of its own condition
+		// actions outlive whatever dispatched them, so only stopping the bot stops them
+		dispatched := *env
+		dispatched.Captures = m.Captures
+		dispatched.Context = b.context()
+		env := &dispatched
 		// shadow a in the goroutine
 		// a iterates through for loop goroutine would otherwise try to use it in closure asynchronously
+		b.pending.Add(1)
 		go func(a Action) {
+			defer b.pending.Done()
 			defer func() {
 				if err := recover(); err != nil {
 					log.Printf("Recovered from panic in perform %T on %v: %v", a, env.Type, err)
@@ -424,6 +557,11 @@ func (b *Bot) dispatch(env *Environment, actions ...Action) {
 			if err != nil {
 				log.Printf("Error in perform %T on %v: %v", a, env.Type, err)
 			}
-		}(a)
+		}(c.Action.Action)
 	}
 }
+
+// CooldownRemaining is how much longer a condition must wait before it can be dispatched again in an environment
+func (b *Bot) CooldownRemaining(env *Environment, c Condition) time.Duration {
+	return b.cooldowns.remaining(env, c)
+}
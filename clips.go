@@ -0,0 +1,149 @@
+package aoebot
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ClipDir is where uploaded clips are kept, each in a file named by the hash of its content
+// The audio file a clip was encoded from is kept next to it, so the clip can be encoded again
+const ClipDir = "media/clips"
+
+const clipExt = ".dca"
+
+const clipSourceExt = ".src"
+
+// files in the clip directory are not pruned until they are this old, so an upload is not removed
+// in between saving its clip and the meme that references it
+const clipPruneGrace = 1 * time.Hour
+
+// Clip describes a sound clip uploaded to the bot
+// The same content is only stored once, and its record is kept from the first upload
+type Clip struct {
+	// ID is the hex encoded sha256 hash of the clip
+	ID         string        `bson:"_id"`
+	Name       string        `bson:"name"`
+	UploadedBy string        `bson:"uploadedby,omitempty"`
+	GuildID    string        `bson:"guild,omitempty"`
+	Duration   time.Duration `bson:"duration"`
+	Size       int64         `bson:"size"`
+}
+
+// ClipFile is the path to the file of a clip
+func ClipFile(id string) string {
+	return filepath.Join(ClipDir, id+clipExt)
+}
+
+// ClipSource is the path to the audio file a clip was encoded from
+func ClipSource(id string) string {
+	return filepath.Join(ClipDir, id+clipSourceExt)
+}
+
+// VoiceActions finds the voice actions of an action, including the choices and steps of random and sequence actions
+func VoiceActions(action Action) []*VoiceAction {
+	switch a := action.(type) {
+	case *VoiceAction:
+		return []*VoiceAction{a}
+	case *RandomAction:
+		vas := []*VoiceAction{}
+		for _, choice := range a.Choices {
+			vas = append(vas, VoiceActions(choice.Action.Action)...)
+		}
+		return vas
+	case *SequenceAction:
+		vas := []*VoiceAction{}
+		for _, step := range a.Steps {
+			vas = append(vas, VoiceActions(step.Action.Action)...)
+		}
+		return vas
+	}
+	return nil
+}
+
+// swapClip makes a condition say a clip instead of another clip
+// swapClip is false when the condition does not say the other clip
+func swapClip(c *Condition, oldID string, newID string) bool {
+	swapped := false
+	for _, va := range VoiceActions(c.Action.Action) {
+		if va.Clip == oldID {
+			va.Clip = newID
+			swapped = true
+		}
+	}
+	if swapped {
+		c.Name = c.GeneratedName()
+	}
+	return swapped
+}
+
+// ConditionsSwapClip makes the enabled custom conditions of a guild that say a clip say another clip instead.
+func (d *Driver) ConditionsSwapClip(guildID string, oldID string, newID string) error {
+	defer d.invalidate()
+	return d.Store.ConditionsSwapClip(guildID, oldID, newID)
+}
+
+// ClipRefs counts the enabled conditions that reference each clip
+func (d *Driver) ClipRefs() (map[string]int, error) {
+	conditions, err := d.ConditionsEnabled()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]int)
+	for _, c := range conditions {
+		// a condition counts once even if it says the same clip in several steps
+		seen := make(map[string]bool)
+		for _, va := range VoiceActions(c.Action.Action) {
+			if va.Clip != "" && !seen[va.Clip] {
+				seen[va.Clip] = true
+				refs[va.Clip]++
+			}
+		}
+	}
+	return refs, nil
+}
+
+// PruneClips deletes the clips and sources that are not referenced by any enabled condition,
+// and any other file in the clip directory that does not belong to a referenced clip
+// PruneClips returns the number of files it deleted and their total size
+func (d *Driver) PruneClips() (files int, size int64, err error) {
+	refs, err := d.ClipRefs()
+	if err != nil {
+		return 0, 0, err
+	}
+	infos, err := ioutil.ReadDir(ClipDir)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	for _, info := range infos {
+		// a clip's files are named by its id and an extension
+		id := strings.SplitN(info.Name(), ".", 2)[0]
+		if info.IsDir() || refs[id] > 0 || time.Since(info.ModTime()) < clipPruneGrace {
+			continue
+		}
+		if err := os.Remove(filepath.Join(ClipDir, info.Name())); err != nil {
+			log.Printf("Error pruning clip file %v: %v", info.Name(), err)
+			continue
+		}
+		files++
+		size += info.Size()
+	}
+	for _, clip := range d.Clips() {
+		if refs[clip.ID] > 0 {
+			continue
+		}
+		if _, err := os.Stat(ClipFile(clip.ID)); err == nil {
+			// still within the grace period
+			continue
+		}
+		if err := d.ClipDelete(clip.ID); err != nil {
+			log.Printf("Error deleting clip %v: %v", clip.ID, err)
+		}
+	}
+	return files, size, nil
+}
@@ -0,0 +1,268 @@
+// aoebot-console drives the bot from a terminal instead of discord.
+// Each line typed is sent as a message by you to the general channel of a simulated guild,
+// so commands and memes can be tried out offline.
+// Lines beginning with / simulate other events:
+//
+//	/react [emoji]  react to the last message with an emoji
+//	/join           join the simulated voice channel
+//	/leave          leave the simulated voice channel
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/bwmarrin/discordgo"
+	"github.com/jeffreymkabot/aoebot"
+	"github.com/jeffreymkabot/aoebot/commands"
+	"github.com/jeffreymkabot/aoebot/fake"
+)
+
+const (
+	guildID        = "guild"
+	textChannelID  = "general"
+	voiceChannelID = "voice"
+)
+
+func main() {
+	cfgFile := flag.String("cfg", "", "Config File Path, optional")
+	bolt := flag.String("bolt", "console.db", "bolt database file to use when the config has no database")
+	username := flag.String("user", "you", "your username")
+	verbose := flag.Bool("v", false, "show all of the bot's logs")
+	flag.Parse()
+
+	var cfg struct {
+		Mongo string
+		Bolt  string
+		Bot   aoebot.Config
+	}
+	cfg.Bot = aoebot.DefaultConfig
+	if *cfgFile != "" {
+		if _, err := toml.DecodeFile(*cfgFile, &cfg); err != nil {
+			log.Fatalf("failed to open cfg file: %v", err)
+		}
+	}
+	if !*verbose {
+		log.SetFlags(0)
+		log.SetOutput(quiet{})
+	}
+
+	dial := aoebot.DialBolt(*bolt)
+	if cfg.Bolt != "" {
+		dial = aoebot.DialBolt(cfg.Bolt)
+	} else if cfg.Mongo != "" {
+		dial = aoebot.DialMongo(cfg.Mongo)
+	}
+
+	self := &discordgo.User{ID: "aoebot", Username: "aoebot", Bot: true}
+	user := &discordgo.User{ID: "user", Username: *username}
+	session := fake.NewSession(self)
+	session.OnMessage = printMessage
+	session.OnReaction = printReaction
+	err := session.AddGuild(&discordgo.Guild{
+		ID:   guildID,
+		Name: "console",
+		// you own the console guild
+		OwnerID: user.ID,
+		Channels: []*discordgo.Channel{
+			{ID: textChannelID, Name: textChannelID, Type: discordgo.ChannelTypeGuildText},
+			{ID: voiceChannelID, Name: voiceChannelID, Type: discordgo.ChannelTypeGuildVoice},
+		},
+		Members: []*discordgo.Member{
+			{User: self},
+			{User: user},
+		},
+		Roles: []*discordgo.Role{
+			{ID: guildID, Name: "@everyone"},
+		},
+	})
+	if err != nil {
+		log.Fatalf("failed to simulate guild %v", err)
+	}
+
+	signalCh := make(chan os.Signal, 2)
+	signal.Notify(signalCh, os.Interrupt)
+
+	// you own the console bot
+	bot, err := aoebot.New("", dial, user.ID, signalCh)
+	if err != nil {
+		log.Fatalf("failed to initialize %v", err)
+	}
+	bot.WithConfig(cfg.Bot)
+	bot.WithSession(session, session.State)
+	bot.AddCommand(
+		&commands.Aoe2{},
+		&commands.Play{},
+		&commands.Clips{},
+		&commands.PruneClips{},
+		commands.Meme(),
+		&commands.AddChannel{},
+		&commands.Welcome{},
+		&commands.Farewell{},
+		commands.Game(),
+		commands.Voice(),
+		&commands.Prefix{},
+		&commands.Roll{},
+		&commands.Source{},
+		&commands.TestAction{},
+	)
+	if err := bot.Start(); err != nil {
+		log.Fatalf("failed to start %v", err)
+	}
+	defer bot.Stop()
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	fmt.Printf("Talking to aoebot as %v in #%v, commands start with %v\n", user.Username, textChannelID, cfg.Bot.Prefix)
+	c := &console{session: session, user: user}
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// answer everything that was typed before quitting
+				bot.Wait()
+				return
+			}
+			if err := c.handle(line); err != nil {
+				fmt.Printf("🤔 %v\n", err)
+			}
+		case <-signalCh:
+			return
+		}
+	}
+}
+
+// console turns lines of input into discord events
+type console struct {
+	session *fake.Session
+	user    *discordgo.User
+	lastID  int
+	last    *discordgo.Message
+}
+
+func (c *console) handle(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "/react":
+		if len(fields) < 2 {
+			return fmt.Errorf("/react [emoji]")
+		}
+		if c.last == nil {
+			return fmt.Errorf("Nothing to react to")
+		}
+		c.session.Emit(&discordgo.MessageReactionAdd{
+			MessageReaction: &discordgo.MessageReaction{
+				UserID:    c.user.ID,
+				MessageID: c.last.ID,
+				ChannelID: textChannelID,
+				GuildID:   guildID,
+				Emoji:     discordgo.Emoji{Name: fields[1]},
+			},
+		})
+		return nil
+	case "/join":
+		return c.voice(voiceChannelID)
+	case "/leave":
+		return c.voice("")
+	}
+
+	c.lastID++
+	m := &discordgo.Message{
+		ID:        "message" + strconv.Itoa(c.lastID),
+		ChannelID: textChannelID,
+		Content:   line,
+		Author:    c.user,
+	}
+	if err := c.session.State.MessageAdd(m); err != nil {
+		return err
+	}
+	c.last = m
+	c.session.Emit(&discordgo.MessageCreate{Message: m})
+	return nil
+}
+
+// voice moves the user to a voice channel, or out of voice when channelID is empty
+func (c *console) voice(channelID string) error {
+	vs := &discordgo.VoiceState{
+		UserID:    c.user.ID,
+		GuildID:   guildID,
+		ChannelID: channelID,
+	}
+	guild, err := c.session.State.Guild(guildID)
+	if err != nil {
+		return err
+	}
+	voiceStates := []*discordgo.VoiceState{}
+	for _, v := range guild.VoiceStates {
+		if v.UserID != c.user.ID {
+			voiceStates = append(voiceStates, v)
+		}
+	}
+	if channelID != "" {
+		voiceStates = append(voiceStates, vs)
+	}
+	guild.VoiceStates = voiceStates
+	c.session.Emit(&discordgo.VoiceStateUpdate{VoiceState: vs})
+	return nil
+}
+
+func printMessage(m *discordgo.Message) {
+	prefix := "aoebot"
+	if m.Tts {
+		prefix += " (tts)"
+	}
+	if m.Content != "" {
+		fmt.Printf("%v: %v\n", prefix, m.Content)
+	}
+	for _, embed := range m.Embeds {
+		fmt.Printf("%v: [%v]\n", prefix, embed.Title)
+		if embed.Description != "" {
+			fmt.Println(embed.Description)
+		}
+		for _, field := range embed.Fields {
+			fmt.Printf("  %v\n    %v\n", field.Name, strings.Replace(field.Value, "\n", "\n    ", -1))
+		}
+	}
+}
+
+func printReaction(r fake.Reaction) {
+	if r.Removed {
+		fmt.Printf("aoebot unreacted %v\n", r.Emoji)
+		return
+	}
+	fmt.Printf("aoebot reacted %v\n", r.Emoji)
+}
+
+// quiet only shows the logs about audio the bot skipped saying and actions that failed
+type quiet struct{}
+
+func (quiet) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, []byte("Say ")) {
+		// the console has no voicebox, so the audio is never played
+		fmt.Printf("aoebot skipped saying %s", bytes.TrimPrefix(p, []byte("Say ")))
+		return len(p), nil
+	}
+	if bytes.HasPrefix(p, []byte("Error ")) {
+		return os.Stdout.Write(p)
+	}
+	return len(p), nil
+}
@@ -22,6 +22,7 @@ func main() {
 	var cfg struct {
 		Token string
 		Mongo string
+		Bolt  string
 		Owner string
 		Bot   aoebot.Config
 	}
@@ -33,25 +34,29 @@ func main() {
 	signalCh := make(chan os.Signal, 2)
 	signal.Notify(signalCh, os.Interrupt, os.Kill)
 
-	bot, err := aoebot.New(cfg.Token, cfg.Mongo, cfg.Owner, signalCh)
+	// prefer an embedded database file when one is configured
+	dial := aoebot.DialMongo(cfg.Mongo)
+	if cfg.Bolt != "" {
+		dial = aoebot.DialBolt(cfg.Bolt)
+	}
+
+	bot, err := aoebot.New(cfg.Token, dial, cfg.Owner, signalCh)
 	if err != nil {
 		log.Fatalf("failed to initialize %v", err)
 	}
 	bot.WithConfig(cfg.Bot)
 	bot.AddCommand(
 		&commands.Aoe2{},
-		&commands.Memes{},
+		&commands.Play{},
+		&commands.Clips{},
+		&commands.PruneClips{},
+		commands.Meme(),
 		&commands.AddChannel{},
-		&commands.AddReact{},
-		&commands.DelReact{},
-		&commands.AddWrite{},
-		&commands.DelWrite{},
-		&commands.AddVoice{},
-		&commands.DelVoice{},
-		&commands.AddGame{},
-		&commands.ListGame{},
-		&commands.IPlay{},
-		&commands.IDontPlay{},
+		&commands.Welcome{},
+		&commands.Farewell{},
+		commands.Game(),
+		commands.Voice(),
+		&commands.Prefix{},
 		&commands.Roll{},
 		&commands.Source{},
 		&commands.TestAction{},
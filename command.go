@@ -5,10 +5,12 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"text/tabwriter"
 	"text/template"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -21,6 +23,9 @@ type Command interface {
 	Long() string
 	Examples() []string
 	IsOwnerOnly() bool
+	IsAdminOnly() bool
+	// Timeout is how long the command may run, or 0 for the bot's default
+	Timeout() time.Duration
 	Ack(env *Environment) string
 	Run(*Environment, []string) error
 }
@@ -57,20 +62,40 @@ func (b *BaseCommand) IsOwnerOnly() bool {
 	return false
 }
 
+func (b *BaseCommand) IsAdminOnly() bool {
+	return false
+}
+
+func (b *BaseCommand) Timeout() time.Duration {
+	return 0
+}
+
 func (b *BaseCommand) Ack(env *Environment) string {
 	return ""
 }
 
+// Run is not used by an ArgsCommand
+func (b *BaseCommand) Run(env *Environment, args []string) error {
+	return errors.New("Not implemented")
+}
+
 type Help struct {
 	BaseCommand
 }
 
 func (h *Help) Name() string {
-	return strings.Fields(h.Usage())[0]
+	return "help"
 }
 
-func (h *Help) Usage() string {
-	return "help [-here] [command]"
+func (h *Help) Schema() Schema {
+	return Schema{
+		Flags: []Flag{
+			{Name: "here", Type: ArgBool, Usage: "respond in same channel"},
+		},
+		Args: []Arg{
+			{Name: "command", Optional: true, Variadic: true},
+		},
+	}
 }
 
 func (h *Help) Short() string {
@@ -85,42 +110,48 @@ func (h *Help) Examples() []string {
 	return []string{
 		"help addchannel",
 		"help -here addchannel",
+		"help meme add write",
 	}
 }
 
-func (h *Help) Run(env *Environment, args []string) error {
-	f := flag.NewFlagSet(h.Name(), flag.ContinueOnError)
-	shouldRespondInline := f.Bool("here", false, "respond in same channel")
-	if err := f.Parse(args); err != nil {
-		return err
+func (h *Help) RunArgs(env *Environment, args *Args) error {
+	embed := h.embed(env)
+	if args.IsSet("command") {
+		if path, rest := resolve(env.Bot.commands, args.Strings("command")); len(path) > 0 && len(rest) == 0 {
+			embed = helpWithCommandEmbed(env, path)
+		}
 	}
 
-	args = f.Args()
-
-	respChannelID := env.TextChannel.ID
 	fromDmChannel := env.TextChannel.Type == discordgo.ChannelTypeDM || env.TextChannel.Type == discordgo.ChannelTypeGroupDM
+	if args.Bool("here") || fromDmChannel {
+		return env.ReplyEmbed(embed)
+	}
 	// open a private msg channel if the message did not come from one
-	if !*shouldRespondInline && !fromDmChannel {
-		if dm, err := env.Bot.Session.UserChannelCreate(env.Author.ID); err != nil {
-			return err
-		} else {
-			respChannelID = dm.ID
-		}
+	dm, err := env.Bot.Session.UserChannelCreate(env.Author.ID)
+	if err != nil {
+		return err
 	}
+	_, err = env.Bot.Session.ChannelMessageSendEmbed(dm.ID, embed)
+	return err
+}
 
-	if len(args) == 1 {
-		for _, c := range env.Bot.commands {
-			if strings.ToLower(args[0]) == strings.ToLower(c.Name()) {
-				embed := helpWithCommandEmbed(env, c)
-				_, err := env.Bot.Session.ChannelMessageSendEmbed(respChannelID, embed)
-				return err
+// Complete suggests the full names of commands
+func (h *Help) Complete(env *Environment, option string, partial string) []string {
+	names := []string{}
+	var walk func(commands []Command, parent []Command)
+	walk = func(commands []Command, parent []Command) {
+		for _, c := range commands {
+			path := append(append([]Command{}, parent...), c)
+			if name := pathName(path); !c.IsOwnerOnly() && strings.HasPrefix(name, strings.ToLower(partial)) {
+				names = append(names, name)
+			}
+			if g, ok := c.(*Group); ok {
+				walk(g.Subcommands, path)
 			}
 		}
 	}
-
-	embed := h.embed(env)
-	_, err := env.Bot.Session.ChannelMessageSendEmbed(respChannelID, embed)
-	return err
+	walk(env.Bot.commands, nil)
+	return names
 }
 
 func (h *Help) Ack(env *Environment) string {
@@ -131,7 +162,7 @@ func (h *Help) Ack(env *Environment) string {
 }
 
 var helpDescTmpl = template.Must(template.New("helpDesc").Parse(
-	"All commands start with `{{.Prefix}}`.\nTo get more help about any command use {{.Prefix}} {{.Usage}}"))
+	"All commands start with `{{.Prefix}}` or {{.Mention}}.\nQuote arguments that have spaces, e.g. \"gotta go fast\".\nTo get more help about any command use {{.Prefix}} {{.Usage}}"))
 
 func (h *Help) embed(env *Environment) *discordgo.MessageEmbed {
 	embed := &discordgo.MessageEmbed{}
@@ -143,23 +174,20 @@ func (h *Help) embed(env *Environment) *discordgo.MessageEmbed {
 		}
 	}
 	data := struct {
-		Prefix string
-		Usage  string
-	}{env.Bot.Config.Prefix, h.Usage()}
+		Prefix  string
+		Mention string
+		Usage   string
+	}{env.Prefix(), env.Bot.Mention(), Usage(h)}
 	buf := &bytes.Buffer{}
 	helpDescTmpl.Execute(buf, data)
 	embed.Description = buf.String()
 	embed.Fields = []*discordgo.MessageEmbedField{}
 	if len(h.Examples()) > 0 {
-		embed.Fields = append(embed.Fields, examplesEmbedField(env.Bot.Config.Prefix, h.Examples()))
+		embed.Fields = append(embed.Fields, examplesEmbedField(env.Prefix(), h.Examples()))
 	}
 	buf.Reset()
 	tw := tabwriter.NewWriter(buf, 4, 4, 0, '.', 0)
-	for _, c := range env.Bot.commands {
-		if !c.IsOwnerOnly() {
-			fmt.Fprintf(tw, "`%s..\t%s`\n", c.Name(), c.Short())
-		}
-	}
+	writeCommandTree(tw, env.Bot.commands, nil, "", env.Bot.permissions(env))
 	tw.Flush()
 	embed.Fields = append(embed.Fields,
 		&discordgo.MessageEmbedField{
@@ -169,9 +197,24 @@ func (h *Help) embed(env *Environment) *discordgo.MessageEmbed {
 	return embed
 }
 
-func helpWithCommandEmbed(env *Environment, cmd Command) *discordgo.MessageEmbed {
+// writeCommandTree writes a line for each command that may be run, with the subcommands of groups indented below them
+func writeCommandTree(w io.Writer, commands []Command, parent []Command, indent string, perms *permissions) {
+	for _, c := range commands {
+		path := append(append([]Command{}, parent...), c)
+		if c.IsOwnerOnly() || !perms.allow(path) {
+			continue
+		}
+		fmt.Fprintf(w, "`%s%s..\t%s`\n", indent, c.Name(), c.Short())
+		if g, ok := c.(*Group); ok {
+			writeCommandTree(w, g.Subcommands, path, indent+"  ", perms)
+		}
+	}
+}
+
+func helpWithCommandEmbed(env *Environment, path []Command) *discordgo.MessageEmbed {
+	cmd := path[len(path)-1]
 	embed := &discordgo.MessageEmbed{}
-	embed.Title = cmd.Name()
+	embed.Title = pathName(path)
 	embed.Color = 0x00ff80
 	if env.Bot.Config.HelpThumbnail != "" {
 		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{
@@ -182,10 +225,21 @@ func helpWithCommandEmbed(env *Environment, cmd Command) *discordgo.MessageEmbed
 	embed.Fields = append(embed.Fields,
 		&discordgo.MessageEmbedField{
 			Name:  "Usage",
-			Value: fmt.Sprintf("`%s %s`", env.Bot.Config.Prefix, cmd.Usage()),
+			Value: fmt.Sprintf("`%s %s`", env.Prefix(), pathUsage(path)),
 		})
+	if g, ok := cmd.(*Group); ok {
+		buf := &bytes.Buffer{}
+		tw := tabwriter.NewWriter(buf, 4, 4, 0, '.', 0)
+		writeCommandTree(tw, g.Subcommands, path, "", env.Bot.permissions(env))
+		tw.Flush()
+		embed.Fields = append(embed.Fields,
+			&discordgo.MessageEmbedField{
+				Name:  "Subcommands",
+				Value: buf.String(),
+			})
+	}
 	if len(cmd.Examples()) > 0 {
-		embed.Fields = append(embed.Fields, examplesEmbedField(env.Bot.Config.Prefix, cmd.Examples()))
+		embed.Fields = append(embed.Fields, examplesEmbedField(env.Prefix(), cmd.Examples()))
 	}
 	embed.Fields = append(embed.Fields,
 		&discordgo.MessageEmbedField{
@@ -235,7 +289,7 @@ func (r *Reconnect) Run(env *Environment, args []string) error {
 	if env.Guild == nil {
 		return errors.New("No guild")
 	}
-	env.Bot.Write(env.TextChannel.ID, "Sure thing 🙂", false)
+	env.Reply("Sure thing 🙂")
 	env.Bot.speakTo(env.Guild)
 	return nil
 }
@@ -265,7 +319,7 @@ func (r *Restart) IsOwnerOnly() bool {
 }
 
 func (r *Restart) Run(env *Environment, args []string) error {
-	env.Bot.Write(env.TextChannel.ID, "Okay dad 👀", false)
+	env.Reply("Okay dad 👀")
 	env.Bot.Stop()
 	return env.Bot.Start()
 }
@@ -300,7 +354,7 @@ func (s *Shutdown) Run(env *Environment, args []string) error {
 	if err := f.Parse(args); err != nil {
 		return err
 	}
-	env.Bot.Write(env.TextChannel.ID, "Are you sure dad? 😳 💤", false)
+	env.Reply("Are you sure dad? 😳 💤")
 	sig := os.Interrupt
 	if *isHard {
 		sig = os.Kill
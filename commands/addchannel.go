@@ -2,8 +2,6 @@ package commands
 
 import (
 	"errors"
-	"flag"
-	"strings"
 
 	"github.com/jeffreymkabot/aoebot"
 )
@@ -13,11 +11,16 @@ type AddChannel struct {
 }
 
 func (ac *AddChannel) Name() string {
-	return strings.Fields(ac.Usage())[0]
+	return "addchannel"
 }
 
-func (ac *AddChannel) Usage() string {
-	return `addchannel [-openmic] [-users n]`
+func (ac *AddChannel) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Flags: []aoebot.Flag{
+			{Name: "openmic", Type: aoebot.ArgBool, Usage: "permit voice activity"},
+			{Name: "users", Type: aoebot.ArgInt, Usage: "limit users to n"},
+		},
+	}
 }
 
 func (ac *AddChannel) Short() string {
@@ -42,15 +45,8 @@ func (a *AddChannel) Examples() []string {
 	}
 }
 
-func (ac *AddChannel) Run(env *aoebot.Environment, args []string) error {
-	f := flag.NewFlagSet("addchannel", flag.ContinueOnError)
-	isOpen := f.Bool("openmic", false, "permit voice activity")
-	userLimit := f.Int("users", 0, "limit users to `n`")
-	err := f.Parse(args)
-	if err != nil {
-		return err
-	}
-
+func (ac *AddChannel) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	isOpen := args.Bool("openmic")
 	if env.Guild == nil {
 		return errors.New("No guild")
 	}
@@ -59,11 +55,11 @@ func (ac *AddChannel) Run(env *aoebot.Environment, args []string) error {
 	}
 
 	chName := "@!" + env.Author.String()
-	if *isOpen {
+	if isOpen {
 		chName = "open" + chName
 	}
 
-	return env.Bot.AddManagedVoiceChannel(env.Guild.ID, chName, aoebot.ChannelOpenMic(*isOpen), aoebot.ChannelUsers(*userLimit))
+	return env.Bot.AddManagedVoiceChannel(env.Guild.ID, chName, aoebot.ChannelOpenMic(isOpen), aoebot.ChannelUsers(args.Int("users")))
 }
 
 func (ac *AddChannel) Ack(env *aoebot.Environment) string {
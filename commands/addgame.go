@@ -15,8 +15,12 @@ func (ag *AddGame) Name() string {
 	return strings.Fields(ag.Usage())[0]
 }
 
+func (ag *AddGame) Aliases() []string {
+	return []string{"addgame"}
+}
+
 func (ag *AddGame) Usage() string {
-	return "addgame [name] [nickname]..."
+	return "add [name] [nickname]..."
 }
 
 func (ag *AddGame) Short() string {
@@ -33,9 +37,9 @@ Separate names and nicknames with spaces.  Names and nicknames cannot contain sp
 
 func (ag *AddGame) Examples() []string {
 	return []string{
-		"addgame pubg",
-		"addgame csgo counterstrike",
-		"addgame leagueoflegends league lol",
+		"game add pubg",
+		"game add csgo counterstrike",
+		"game add leagueoflegends league lol",
 	}
 }
 
@@ -59,7 +63,7 @@ type ListGame struct {
 }
 
 func (lg *ListGame) Aliases() []string {
-	return []string{"games"}
+	return []string{"games", "listgames"}
 }
 
 func (lg *ListGame) Name() string {
@@ -67,11 +71,11 @@ func (lg *ListGame) Name() string {
 }
 
 func (lg *ListGame) Usage() string {
-	return "listgames"
+	return "list"
 }
 
 func (lg *ListGame) Short() string {
-	return "List games created with addgame"
+	return "List games created with game add"
 }
 
 func (lg *ListGame) Run(env *aoebot.Environment, args []string) error {
@@ -80,5 +84,5 @@ func (lg *ListGame) Run(env *aoebot.Environment, args []string) error {
 		return errors.New("no games")
 	}
 	msg := "some games\n`" + strings.Join(games, "`\n`") + "`"
-	return env.Bot.Write(env.TextChannel.ID, msg, false)
+	return env.Reply(msg)
 }
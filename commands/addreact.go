@@ -2,52 +2,30 @@ package commands
 
 import (
 	"errors"
-	"flag"
 	"log"
-	"regexp"
-	"strings"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jeffreymkabot/aoebot"
 )
 
-// emojis have a special text representation for guild custom emojis
-var reactCmdRegexp = regexp.MustCompile(`^(?:<:(\S+:\S+)>|(\S.*)) on "(\S.*)"$`)
-
-func parseReactCmd(arg string, usage string) (emoji string, phrase string, err error) {
-	submatches := reactCmdRegexp.FindStringSubmatch(arg)
-	if submatches == nil {
-		err = errors.New(usage)
-		return
-	}
-
-	if submatches[1] != "" {
-		emoji = submatches[1]
-	} else if submatches[2] != "" {
-		emoji = submatches[2]
-	} else {
-		err = errors.New("Couldn't parse emoji")
-		return
-	}
-
-	if submatches[3] == "" {
-		err = errors.New("Couldn't parse phrase")
-		return
-	}
-	phrase = strings.ToLower(submatches[3])
-	return
-}
-
 type AddReact struct {
 	aoebot.BaseCommand
 }
 
 func (a *AddReact) Name() string {
-	return strings.Fields(a.Usage())[0]
+	return "react"
+}
+
+func (a *AddReact) Aliases() []string {
+	return []string{"addreact"}
 }
 
-func (a *AddReact) Usage() string {
-	return `addreact [-regex] [emoji] on "[phrase]"`
+func (a *AddReact) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Flags:     append([]aoebot.Flag{regexFlag, matchFlag}, addMemeFlags...),
+		Args:      memeArgs("emoji", aoebot.ArgEmoji),
+		Exclusive: memeExclusive,
+	}
 }
 
 func (a *AddReact) Short() string {
@@ -57,36 +35,46 @@ func (a *AddReact) Short() string {
 func (a *AddReact) Long() string {
 	return `Create an automatic reaction when a message matches [phrase].
 [phrase] is not case-sensitive and normally needs to match the entire message.
+Use the [-match] flag to react when the message contains [phrase], starts with [phrase], or has [phrase] as a whole word.
+The [-match] mode can be one of contains, prefix, or word.
+Use the [-reaction] flag to respond when someone reacts with an emoji to a message that matches [phrase] instead, or to any message when [phrase] is "".
 Alternatively, use the [-regex] flag to treat phrase as a regular expression.
 Use a regular expression to match against patterns in the message instead of the entire message.
 Supprted regex described here: https://github.com/google/re2/wiki/Syntax.
-Reactions can be removed with the delreact command.`
+Use the [-cooldown] flag to wait some time before reacting again, e.g. 30s or 5m.
+The cooldown is shared by everyone unless the [-per] scope is user or channel.
+Use the [-chance] flag to only react some percent of the time.
+Use the [-or] flag to add an alternative to an existing meme on the same phrase, instead of creating a new one.
+I will pick one of the alternatives at random.  Use the [-weight] flag to make an alternative more likely to be picked.
+Use the [-then] flag to add a step that follows an existing meme on the same phrase.  Use the [-delay] flag to wait before the step.
+Reactions can be removed with the meme del react command.`
 }
 
 func (a *AddReact) Examples() []string {
 	return []string{
-		`addreact :cat: on "meow"`,
-		`addreact -regex :wave: on "^hi(,? aoebot)?[!?]?$"`,
+		`meme add react :cat: on "meow"`,
+		`meme add react -match contains :cat: on "meow"`,
+		`meme add react -cooldown 30s -per channel :eyes: on "sus"`,
+		`meme add react -or :dog: on "meow"`,
+		`meme add react -then -delay 2s :eyes: on "meow"`,
+		`meme add react -regex :wave: on "^hi(,? aoebot)?[!?]?$"`,
 	}
 }
 
-func (a *AddReact) Run(env *aoebot.Environment, args []string) error {
-	f := flag.NewFlagSet(a.Name(), flag.ContinueOnError)
-	isRegex := f.Bool("regex", false, "parse phrase as a regular expression")
-	err := f.Parse(args)
-	if err != nil {
+func (a *AddReact) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	if err := checkAddMeme(env, args); err != nil {
 		return err
 	}
-	if env.Guild == nil {
-		return errors.New("No guild")
-	}
-	if len(env.Bot.Driver.ConditionsGuild(env.Guild.ID)) >= env.Bot.Config.MaxManagedConditions {
-		return errors.New("I'm not allowed make any more memes in this guild")
-	}
 
-	argString := strings.Join(f.Args(), " ")
-	emoji, phrase, err := parseReactCmd(argString, a.Usage())
-	if err != nil {
+	emoji := args.String("emoji")
+	cond := &aoebot.Condition{
+		EnvironmentType: aoebot.Message,
+		GuildID:         env.Guild.ID,
+		Action: aoebot.NewActionEnvelope(&aoebot.ReactAction{
+			Emoji: emoji,
+		}),
+	}
+	if err := setPhrase(cond, args); err != nil {
 		return err
 	}
 
@@ -101,25 +89,7 @@ func (a *AddReact) Run(env *aoebot.Environment, args []string) error {
 		return err
 	}
 
-	cond := &aoebot.Condition{
-		EnvironmentType: aoebot.Message,
-		GuildID:         env.Guild.ID,
-		Action: aoebot.NewActionEnvelope(&aoebot.ReactAction{
-			Emoji: emoji,
-		}),
-	}
-	if *isRegex {
-		regexPhrase, err := regexp.Compile(phrase)
-		if err != nil {
-			unreact()
-			return err
-		}
-		cond.RegexPhrase = regexPhrase.String()
-	} else {
-		cond.Phrase = strings.ToLower(phrase)
-	}
-	err = env.Bot.Driver.ConditionAdd(cond, env.Author.String())
-	if err != nil {
+	if err := addMeme(env, cond, args); err != nil {
 		unreact()
 		return err
 	}
@@ -135,11 +105,19 @@ type DelReact struct {
 }
 
 func (a *DelReact) Name() string {
-	return strings.Fields(a.Usage())[0]
+	return "react"
+}
+
+func (a *DelReact) Aliases() []string {
+	return []string{"delreact"}
 }
 
-func (a *DelReact) Usage() string {
-	return `delreact [-regex] [emoji] on "[phrase]"`
+func (a *DelReact) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Flags:     append([]aoebot.Flag{regexFlag, matchFlag}, delMemeFlags...),
+		Args:      memeArgs("emoji", aoebot.ArgEmoji),
+		Exclusive: memeExclusive,
+	}
 }
 
 func (a *DelReact) Short() string {
@@ -147,53 +125,36 @@ func (a *DelReact) Short() string {
 }
 
 func (a *DelReact) Long() string {
-	return `Remove an automatic reaction created by addreact.
+	return `Remove an automatic reaction created by meme add react.
+Use the same [-match] mode that was used to create the reaction.
+Use the [-or] flag to remove an alternative added with meme add react -or, or the [-then] flag to remove a step added with meme add react -then.
 Use the [-regex] flag if [phrase] should be treated as a regular expression.
 Accepted syntax described here: https://github.com/google/re2/wiki/Syntax.`
 }
 
 func (d *DelReact) Examples() []string {
 	return []string{
-		`delreact :cat: on "meow"`,
-		`delreact -regex :wave: on "^hi(,? aoebot)?[!?]?$"`,
+		`meme del react :cat: on "meow"`,
+		`meme del react -regex :wave: on "^hi(,? aoebot)?[!?]?$"`,
 	}
 }
 
-func (a *DelReact) Run(env *aoebot.Environment, args []string) error {
-	f := flag.NewFlagSet(a.Name(), flag.ContinueOnError)
-	isRegex := f.Bool("regex", false, "parse phrase as a regular expression")
-	err := f.Parse(args)
-	if err != nil {
-		return err
-	}
+func (a *DelReact) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
 	if env.Guild == nil {
 		return errors.New("No guild")
 	}
 
-	argString := strings.Join(f.Args(), " ")
-	emoji, phrase, err := parseReactCmd(argString, a.Usage())
-	if err != nil {
-		return err
-	}
-
 	cond := &aoebot.Condition{
 		EnvironmentType: aoebot.Message,
 		GuildID:         env.Guild.ID,
 		Action: aoebot.NewActionEnvelope(&aoebot.ReactAction{
-			Emoji: emoji,
+			Emoji: args.String("emoji"),
 		}),
 	}
-	if *isRegex {
-		regexPhrase, err := regexp.Compile(phrase)
-		if err != nil {
-			return err
-		}
-		cond.RegexPhrase = regexPhrase.String()
-	} else {
-		cond.Phrase = strings.ToLower(phrase)
+	if err := setPhrase(cond, args); err != nil {
+		return err
 	}
-
-	return env.Bot.Driver.ConditionDisable(cond)
+	return delMeme(env, cond, args)
 }
 
 func (a *DelReact) Ack(env *aoebot.Environment) string {
@@ -1,14 +1,16 @@
 package commands
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"regexp"
 	"strings"
 	"time"
 
@@ -16,21 +18,12 @@ import (
 	"github.com/jonas747/dca"
 )
 
-var addvoiceCmdRegexp = regexp.MustCompile(`^on "(\S.*)"$`)
-
-func parseAddVoiceCmd(arg string, usage string) (phrase string, err error) {
-	submatches := addvoiceCmdRegexp.FindStringSubmatch(arg)
-	if submatches == nil {
-		err = errors.New(usage)
-		return
-	}
-
-	if submatches[1] == "" {
-		err = errors.New("Couldn't parse phrase")
-		return
-	}
-	phrase = strings.ToLower(submatches[1])
-	return
+// encodeFlags are the flags of the commands that encode clips
+var encodeFlags = []aoebot.Flag{
+	{Name: "af", Value: "filters", Default: dca.StdEncodeOptions.AudioFilter, Usage: "ffmpeg filters"},
+	{Name: "start", Type: aoebot.ArgDuration, Usage: "skip whole seconds of the audio file"},
+	{Name: "length", Type: aoebot.ArgDuration, Usage: "take duration of the audio file"},
+	{Name: "volume", Type: aoebot.ArgInt, Value: "dB", Usage: "make the clip louder or quieter"},
 }
 
 type AddVoice struct {
@@ -38,11 +31,34 @@ type AddVoice struct {
 }
 
 func (a *AddVoice) Name() string {
-	return strings.Fields(a.Usage())[0]
+	return "voice"
+}
+
+func (a *AddVoice) Aliases() []string {
+	return []string{"addvoice"}
+}
+
+func (a *AddVoice) Schema() aoebot.Schema {
+	flags := append([]aoebot.Flag{}, encodeFlags...)
+	flags = append(flags, matchFlag)
+	return aoebot.Schema{
+		Flags: append(flags, addMemeFlags...),
+		Args: []aoebot.Arg{
+			{Name: "on", Type: aoebot.ArgLiteral},
+			{Name: "phrase", Type: aoebot.ArgPhrase},
+		},
+		Exclusive: memeExclusive,
+	}
 }
 
-func (a *AddVoice) Usage() string {
-	return `addvoice on "[phrase]"`
+// the slash command needs an option to attach the audio file
+func (a *AddVoice) Upload() string {
+	return `the audio file to make the clip from`
+}
+
+// downloading and encoding an audio file can take a while
+func (a *AddVoice) Timeout() time.Duration {
+	return 2 * time.Minute
 }
 
 func (a *AddVoice) Short() string {
@@ -53,46 +69,44 @@ func (a *AddVoice) Long() string {
 	return `Create an automatic audio response when a message matches [phrase].
 You need to attach an audio file to the same message that invokes this command.
 I will only take the first couple of seconds from the audio file.
-Phrase is not case-sensitive and needs to match the entire message content to trigger the response.
-Responses can be removed with the delvoice command.`
+Use the [-start] flag to skip a whole number of seconds of the audio file, e.g. 1m30s, and the [-length] flag to take less of it, e.g. 1.5s.
+Use the [-volume] flag to make the clip some decibels louder or quieter than usual, e.g. 6 or -6.
+Use the [-af] flag for any other ffmpeg audio filters.
+I keep the audio file, so the clip can be made again with different flags with the meme edit voice command.
+Phrase is not case-sensitive and normally needs to match the entire message content to trigger the response.
+Use the [-match] flag to trigger the response when the message contains [phrase], starts with [phrase], or has [phrase] as a whole word.
+The [-match] mode can be one of contains, prefix, or word.
+Use the [-reaction] flag to respond when someone reacts with an emoji to a message that matches [phrase] instead, or to any message when [phrase] is "".
+Use the [-cooldown] flag to wait some time before responding again, e.g. 30s or 5m.
+The cooldown is shared by everyone unless the [-per] scope is user or channel.
+Use the [-chance] flag to only respond some percent of the time.
+Use the [-or] flag to add an alternative to an existing meme on the same phrase, instead of creating a new one.
+I will pick one of the alternatives at random.  Use the [-weight] flag to make an alternative more likely to be picked.
+Use the [-then] flag to add a step that follows an existing meme on the same phrase.  Use the [-delay] flag to wait before the step.
+Responses can be removed with the meme del voice command.`
 }
 
 func (a *AddVoice) Examples() []string {
 	return []string{
-		`addvoice on "skrrt"`,
-		`addvoice on "gotta go fast"`,
+		`meme add voice on "skrrt"`,
+		`meme add voice on "gotta go fast"`,
+		`meme add voice -match word on "sanic"`,
+		`meme add voice -start 1m30s -length 2s on "gotta go fast"`,
+		`meme add voice -volume -6 on "skrrt"`,
+		`meme add voice -reaction 🔊 on ""`,
+		`meme add voice -cooldown 5m on "skrrt"`,
+		`meme add voice -or -weight 3 on "skrrt"`,
 	}
 }
 
-func (a *AddVoice) Run(env *aoebot.Environment, args []string) error {
-	f := flag.NewFlagSet(a.Name(), flag.ContinueOnError)
-	filters := f.String("af", dca.StdEncodeOptions.AudioFilter, "ffmpeg filters")
-	err := f.Parse(args)
-	if err != nil {
+func (a *AddVoice) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	if err := checkAddMeme(env, args); err != nil {
 		return err
 	}
-
-	if env.Guild == nil {
-		return errors.New("No guild")
-	}
-	if len(env.Bot.Driver.ConditionsGuild(env.Guild.ID)) >= env.Bot.Config.MaxManagedConditions {
-		return errors.New("I'm not allowed make any more memes in this guild")
-	}
 	if len(env.TextMessage.Attachments) == 0 {
 		return errors.New("No attached file")
 	}
-
-	argString := strings.Join(f.Args(), " ")
-	phrase, err := parseAddVoiceCmd(argString, a.Usage())
-	if err != nil {
-		return err
-	}
-
-	url := env.TextMessage.Attachments[0].URL
-	filename := env.TextMessage.Attachments[0].Filename
-	duration := time.Duration(env.Bot.Config.MaxManagedVoiceDuration) * time.Second
-	file, err := dcaFromURL(url, filename, duration, withFilters(*filters))
-	if err != nil {
+	if err := checkEncode(args); err != nil {
 		return err
 	}
 
@@ -107,42 +121,205 @@ func (a *AddVoice) Run(env *aoebot.Environment, args []string) error {
 	cond := &aoebot.Condition{
 		EnvironmentType: aoebot.Message,
 		GuildID:         env.Guild.ID,
-		Phrase:          phrase,
 		TextChannelID:   textChannelID,
-		Action: aoebot.NewActionEnvelope(&aoebot.VoiceAction{
-			File:  file.Name(),
-			Alias: filename,
-		}),
+	}
+	if err := setPhrase(cond, args); err != nil {
+		return err
 	}
 
-	return env.Bot.Driver.ConditionAdd(cond, env.Author.String())
+	url := env.TextMessage.Attachments[0].URL
+	filename := env.TextMessage.Attachments[0].Filename
+	clip, err := dcaFromURL(env.Context, url, maxClipDuration(env, args), optionsFromFlags(args)...)
+	if err != nil {
+		return err
+	}
+	clip.Name = filename
+	clip.UploadedBy = env.Author.String()
+	clip.GuildID = env.Guild.ID
+	if err := env.Bot.Driver.ClipAdd(clip); err != nil {
+		return err
+	}
+	cond.Action = aoebot.NewActionEnvelope(&aoebot.VoiceAction{
+		Clip:  clip.ID,
+		Alias: filename,
+	})
+	if err := addMeme(env, cond, args); err != nil {
+		return err
+	}
+	return env.Reply(fmt.Sprintf("Made a %.1fs clip from %s", clip.Duration.Seconds(), filename))
 }
 
 func (a *AddVoice) Ack(env *aoebot.Environment) string {
 	return "✅"
 }
 
-type encodeOption func(*dca.EncodeOptions)
+// encoding are the options for encoding an audio file into a clip
+type encoding struct {
+	*dca.EncodeOptions
+	// gain raises or lowers the loudness the limiter aims for, in dB
+	gain int
+}
+
+type encodeOption func(*encoding)
 
 func withFilters(filters string) encodeOption {
-	return func(enc *dca.EncodeOptions) {
+	return func(enc *encoding) {
 		if strings.TrimSpace(filters) != "" {
 			enc.AudioFilter = filters
 		}
 	}
 }
 
-const limiterFilter = "loudnorm=i=-29"
+// withStart seeks into the audio file before encoding it
+// ffmpeg is told to seek to a whole second
+func withStart(start time.Duration) encodeOption {
+	return func(enc *encoding) {
+		enc.StartTime = int(start / time.Second)
+	}
+}
 
+// withGain makes a clip louder or quieter
+// The gain is applied by the limiter, which would otherwise undo a gain applied before it
+func withGain(gain int) encodeOption {
+	return func(enc *encoding) {
+		enc.gain = gain
+	}
+}
+
+const limiterFilter = "loudnorm=i=%d"
+
+// the limiter aims for this loudness in LUFS, ffmpeg's loudnorm accepts -70 to -5
+const limiterLoudness = -29
+
+// a gain can not push the limiter's loudness out of range
+const maxGain = 20
+
+// optionsFromFlags are the encode options set by the flags in encodeFlags
+func optionsFromFlags(args *aoebot.Args) []encodeOption {
+	return []encodeOption{
+		withFilters(args.String("af")),
+		withStart(args.Duration("start")),
+		withGain(args.Int("volume")),
+	}
+}
+
+// maxClipDuration is the duration the -length flag asks for, up to the longest clip the bot is allowed to make
+func maxClipDuration(env *aoebot.Environment, args *aoebot.Args) time.Duration {
+	duration := time.Duration(env.Bot.Config.MaxManagedVoiceDuration) * time.Second
+	if length := args.Duration("length"); length > 0 && length < duration {
+		duration = length
+	}
+	return duration
+}
+
+// checkEncode is an error unless the flags in encodeFlags can encode a clip
+func checkEncode(args *aoebot.Args) error {
+	if err := checkTrim(args.Duration("start"), args.Duration("length")); err != nil {
+		return err
+	}
+	return checkGain(args.Int("volume"))
+}
+
+// checkGain is an error unless the limiter can apply a gain
+func checkGain(gain int) error {
+	if gain < -maxGain || gain > maxGain {
+		return fmt.Errorf("Volume should be between -%v and %v dB, not %v", maxGain, maxGain, gain)
+	}
+	return nil
+}
+
+// checkTrim is an error unless start and length can trim an audio file
+func checkTrim(start time.Duration, length time.Duration) error {
+	if start < 0 {
+		return errors.New("Start can't be negative")
+	}
+	// dca only skips whole seconds
+	if start%time.Second != 0 {
+		return fmt.Errorf("Start has to be a whole number of seconds, not %v", start)
+	}
+	if length < 0 {
+		return errors.New("Length can't be negative")
+	}
+	return nil
+}
+
+// voice clips uploaded before the clip library were written here, named by their attachment filename
 const voiceFilePathTmpl = "media/audio/%s.dca"
 
-func dcaFromURL(url string, fname string, maxDuration time.Duration, options ...encodeOption) (*os.File, error) {
-	resp, err := http.Get(url)
+// dcaFromURL downloads an audio file, encodes it, and saves both to the clip library, named by the hash of the encoded clip
+// The same audio encoded with the same options is only saved once
+func dcaFromURL(ctx context.Context, url string, maxDuration time.Duration, options ...encodeOption) (*aoebot.Clip, error) {
+	if err := os.MkdirAll(aoebot.ClipDir, 0755); err != nil {
+		return nil, err
+	}
+	source, err := download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(source)
+
+	encoded, clip, err := encodeClip(ctx, source, maxDuration, options...)
 	if err != nil {
 		return nil, err
 	}
+	defer os.Remove(encoded)
+
+	if err := saveClip(clip.ID, source, encoded); err != nil {
+		return nil, err
+	}
+	return clip, nil
+}
+
+// saveClip moves an encoded clip and the audio file it was made from into the clip library
+// A clip that is already saved has the same content, so it is kept instead
+func saveClip(id string, source string, encoded string) error {
+	if err := keepFile(source, aoebot.ClipSource(id)); err != nil {
+		return err
+	}
+	return keepFile(encoded, aoebot.ClipFile(id))
+}
+
+// keepFile moves a file unless there is already a file at its destination
+func keepFile(from string, to string) error {
+	if _, err := os.Stat(to); !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+// download writes an audio file to a temporary file in the clip library
+func download(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Couldn't download the file: %v", resp.Status)
+	}
+
+	f, err := ioutil.TempFile(aoebot.ClipDir, "upload-")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
 
+// encodeClip encodes an audio file to a temporary file in the clip library
+// The clip is named by the hash of the encoded audio
+func encodeClip(ctx context.Context, source string, maxDuration time.Duration, options ...encodeOption) (string, *aoebot.Clip, error) {
 	var encodeOptions = &dca.EncodeOptions{
 		Volume:           256,
 		Channels:         2,
@@ -155,69 +332,71 @@ func dcaFromURL(url string, fname string, maxDuration time.Duration, options ...
 		BufferedFrames:   100,
 		VBR:              true,
 	}
+	enc := &encoding{EncodeOptions: encodeOptions}
 	for _, opt := range options {
-		opt(encodeOptions)
+		opt(enc)
 	}
 	// apply a limiter at the end of the signal chain
 	if encodeOptions.AudioFilter != "" {
 		encodeOptions.AudioFilter += ", "
 	}
-	encodeOptions.AudioFilter += limiterFilter
+	encodeOptions.AudioFilter += fmt.Sprintf(limiterFilter, limiterLoudness+enc.gain)
 
-	encoder, err := dca.EncodeMem(resp.Body, encodeOptions)
+	in, err := os.Open(source)
 	if err != nil {
-		return nil, err
+		return "", nil, err
+	}
+	defer in.Close()
+	encoder, err := dca.EncodeMem(in, encodeOptions)
+	if err != nil {
+		return "", nil, err
 	}
 	defer encoder.Cleanup()
 
-	f, err := os.Create(fmt.Sprintf(voiceFilePathTmpl, fname))
+	// write to a temporary file until the hash is known
+	f, err := ioutil.TempFile(aoebot.ClipDir, "encode-")
 	if err != nil {
-		return nil, err
+		return "", nil, err
+	}
+	clip, err := writeFrames(ctx, encoder, f, maxDuration)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
 	}
-	defer f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", nil, err
+	}
+	return f.Name(), clip, nil
+}
 
+// writeFrames writes the frames of an encoded clip until it is maxDuration long
+func writeFrames(ctx context.Context, encoder *dca.EncodeSession, f *os.File, maxDuration time.Duration) (*aoebot.Clip, error) {
+	hash := sha256.New()
+	w := io.MultiWriter(f, hash)
+	clip := &aoebot.Clip{}
 	frameDuration := encoder.FrameDuration()
 
 	// count frames to make sure we do not exceed the maximum allowed file size
-	var frame []byte
-	for fileDuration := time.Duration(0); fileDuration < maxDuration; fileDuration += frameDuration {
-		frame, err = encoder.ReadFrame()
-		if err != nil {
-			if err == io.EOF {
-				return f, nil
-			}
+	for clip.Duration < maxDuration {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		frame, err := encoder.ReadFrame()
+		if err == io.EOF {
+			break
+		} else if err != nil {
 			return nil, err
 		}
 
-		_, err = f.Write(frame)
+		n, err := w.Write(frame)
 		if err != nil {
 			return nil, err
 		}
+		clip.Size += int64(n)
+		clip.Duration += frameDuration
 	}
-	return f, nil
-}
-
-var delvoiceCmdRegexp = regexp.MustCompile(`^"(\S.*)" on "(\S.*)"$`)
-
-func parseDelVoiceCmd(arg string, usage string) (filename string, phrase string, err error) {
-	submatches := delvoiceCmdRegexp.FindStringSubmatch(arg)
-	if submatches == nil {
-		err = errors.New(usage)
-		return
-	}
-
-	if submatches[1] == "" {
-		err = errors.New("Couldn't parse filename")
-		return
-	}
-	filename = submatches[1]
-
-	if submatches[2] == "" {
-		err = errors.New("Couldn't parse phrase")
-		return
-	}
-	phrase = strings.ToLower(submatches[2])
-	return
+	clip.ID = hex.EncodeToString(hash.Sum(nil))
+	return clip, nil
 }
 
 type DelVoice struct {
@@ -225,11 +404,19 @@ type DelVoice struct {
 }
 
 func (d *DelVoice) Name() string {
-	return strings.Fields(d.Usage())[0]
+	return "voice"
 }
 
-func (d *DelVoice) Usage() string {
-	return `delvoice "[filename]" on "[phrase]"`
+func (d *DelVoice) Aliases() []string {
+	return []string{"delvoice"}
+}
+
+func (d *DelVoice) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Flags:     append([]aoebot.Flag{matchFlag}, delMemeFlags...),
+		Args:      memeArgs("filename", aoebot.ArgPhrase),
+		Exclusive: memeExclusive,
+	}
 }
 
 func (d *DelVoice) Short() string {
@@ -237,41 +424,65 @@ func (d *DelVoice) Short() string {
 }
 
 func (d *DelVoice) Long() string {
-	return `Remove an automatic audio response created by addvoice.
+	return `Remove an automatic audio response created by meme add voice.
 Suppose there is a response created using the file "greenhillzone.wav" on the phrase "gotta go fast".
 This response can be deleted with:
-delvoice "greenhillzone.wav" on "gotta go fast"`
+meme del voice "greenhillzone.wav" on "gotta go fast"
+Use the same [-match] mode that was used to create the response.
+Use the [-or] flag to remove an alternative added with meme add voice -or, or the [-then] flag to remove a step added with meme add voice -then.`
 }
 
 func (d *DelVoice) Examples() []string {
 	return []string{
-		`delvoice "greenhillzone.wav" on "gotta go fast"`,
+		`meme del voice "greenhillzone.wav" on "gotta go fast"`,
 	}
 }
 
-func (d *DelVoice) Run(env *aoebot.Environment, args []string) error {
+func (d *DelVoice) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
 	if env.Guild == nil {
 		return errors.New("No guild")
 	}
 
-	argString := strings.Join(args, " ")
-	filename, phrase, err := parseDelVoiceCmd(argString, d.Usage())
-	if err != nil {
-		return err
+	filename := args.String("filename")
+	if filename == "" {
+		return errors.New("Couldn't parse filename")
 	}
-
 	cond := &aoebot.Condition{
 		EnvironmentType: aoebot.Message,
 		GuildID:         env.Guild.ID,
-		Phrase:          phrase,
-		Action: aoebot.NewActionEnvelope(&aoebot.VoiceAction{
-			// TODO why does it need both ??
-			Alias: filename,
-			File:  fmt.Sprintf(voiceFilePathTmpl, filename),
-		}),
 	}
+	if err := setPhrase(cond, args); err != nil {
+		return err
+	}
+	cond.Action = aoebot.NewActionEnvelope(guildVoiceAction(env, cond, filename))
+	return delMeme(env, cond, args)
+}
 
-	return env.Bot.Driver.ConditionDisable(cond)
+// guildVoiceAction finds the voice action that says a file in the memes of a guild, preferring the memes on the same phrase as cond
+// Clips uploaded before the clip library are referenced by their path instead of an id
+func guildVoiceAction(env *aoebot.Environment, cond *aoebot.Condition, filename string) *aoebot.VoiceAction {
+	var found *aoebot.VoiceAction
+	for _, c := range env.Bot.Driver.ConditionsGuild(env.Guild.ID) {
+		samePhrase := c.Phrase == cond.Phrase && sameRegex(c.RegexPhrase, cond.RegexPhrase)
+		for _, va := range aoebot.VoiceActions(c.Action.Action) {
+			if va.Alias != filename {
+				continue
+			}
+			if samePhrase {
+				return va
+			}
+			if found == nil {
+				found = va
+			}
+		}
+	}
+	if found != nil {
+		return found
+	}
+	return &aoebot.VoiceAction{
+		Alias: filename,
+		File:  fmt.Sprintf(voiceFilePathTmpl, filename),
+	}
 }
 
 func (a *DelVoice) Ack(env *aoebot.Environment) string {
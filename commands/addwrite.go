@@ -2,45 +2,28 @@ package commands
 
 import (
 	"errors"
-	"regexp"
-	"strings"
 
 	"github.com/jeffreymkabot/aoebot"
 )
 
-var writeCmdRegexp = regexp.MustCompile(`^"(\S.*)" on "(\S.*)"$`)
-
-func parseWriteCmd(arg string, usage string) (response string, phrase string, err error) {
-	submatches := writeCmdRegexp.FindStringSubmatch(arg)
-	if submatches == nil {
-		err = errors.New(usage)
-		return
-	}
-
-	if submatches[1] == "" {
-		err = errors.New("Couldn't parse response")
-		return
-	}
-	response = submatches[1]
-
-	if submatches[2] == "" {
-		err = errors.New("Couldn't parse phrase")
-		return
-	}
-	phrase = strings.ToLower(submatches[2])
-	return
-}
-
 type AddWrite struct {
 	aoebot.BaseCommand
 }
 
 func (a *AddWrite) Name() string {
-	return strings.Fields(a.Usage())[0]
+	return "write"
 }
 
-func (a *AddWrite) Usage() string {
-	return `addwrite "[response]" on "[phrase]"`
+func (a *AddWrite) Aliases() []string {
+	return []string{"addwrite"}
+}
+
+func (a *AddWrite) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Flags:     append([]aoebot.Flag{regexFlag, matchFlag}, addMemeFlags...),
+		Args:      memeArgs("response", aoebot.ArgPhrase),
+		Exclusive: memeExclusive,
+	}
 }
 
 func (a *AddWrite) Short() string {
@@ -49,41 +32,56 @@ func (a *AddWrite) Short() string {
 
 func (a *AddWrite) Long() string {
 	return `Create an automatic response when a message matches [phrase].
-[phrase] is not case-sensitive and needs to match the entire message content to trigger the response.
-Responses can be removed with the delwrite command.`
+[phrase] is not case-sensitive and normally needs to match the entire message content to trigger the response.
+Use the [-match] flag to trigger the response when the message contains [phrase], starts with [phrase], or has [phrase] as a whole word.
+The [-match] mode can be one of contains, prefix, or word.
+Use the [-reaction] flag to respond when someone reacts with an emoji to a message that matches [phrase] instead, or to any message when [phrase] is "".
+Alternatively, use the [-regex] flag to treat phrase as a regular expression.
+Supprted regex described here: https://github.com/google/re2/wiki/Syntax.
+[response] can be a template, e.g. {{.Author.Mention}}, {{.Author.Username}}, {{.Channel.Name}}, {{.Guild.Name}}, or {{.Random 100}}.
+With the [-regex] flag, {{index .Captures 1}} is the first group captured by [phrase].
+Template syntax described here: https://golang.org/pkg/text/template.
+Use the [-cooldown] flag to wait some time before responding again, e.g. 30s or 5m.
+The cooldown is shared by everyone unless the [-per] scope is user or channel.
+Use the [-chance] flag to only respond some percent of the time.
+Use the [-or] flag to add an alternative to an existing meme on the same phrase, instead of creating a new one.
+I will pick one of the alternatives at random.  Use the [-weight] flag to make an alternative more likely to be picked.
+Use the [-then] flag to add a step that follows an existing meme on the same phrase.  Use the [-delay] flag to wait before the step.
+Responses can be removed with the meme del write command.`
 }
 
 func (a *AddWrite) Examples() []string {
 	return []string{
-		`addwrite "pong" on "ping"`,
-		`addwrite ":alien: ayy lmao :alien:" on "it's dat boi"`,
+		`meme add write "pong" on "ping"`,
+		`meme add write ":alien: ayy lmao :alien:" on "it's dat boi"`,
+		`meme add write -match word "nice" on "69"`,
+		`meme add write "hi {{.Author.Mention}}" on "hello"`,
+		`meme add write -regex "{{index .Captures 1}}? I hardly know her" on "(\w+)er$"`,
+		`meme add write -cooldown 1m -per user "stop it" on "no u"`,
+		`meme add write -chance 10 "gesundheit" on "achoo"`,
+		`meme add write -or -weight 2 "pang" on "ping"`,
+		`meme add write -then -delay 2s "jk" on "ping"`,
 	}
 }
 
-func (a *AddWrite) Run(env *aoebot.Environment, args []string) error {
-	if env.Guild == nil {
-		return errors.New("No guild")
-	}
-	if len(env.Bot.Driver.ConditionsGuild(env.Guild.ID)) >= env.Bot.Config.MaxManagedConditions {
-		return errors.New("I'm not allowed make any more memes in this guild")
+func (a *AddWrite) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	if err := checkAddMeme(env, args); err != nil {
+		return err
 	}
 
-	argString := strings.Join(args, " ")
-	response, phrase, err := parseWriteCmd(argString, a.Usage())
+	action, err := writeAction(args)
 	if err != nil {
 		return err
 	}
-
 	cond := &aoebot.Condition{
 		EnvironmentType: aoebot.Message,
 		GuildID:         env.Guild.ID,
-		Phrase:          phrase,
-		Action: aoebot.NewActionEnvelope(&aoebot.WriteAction{
-			Content: response,
-		}),
+		Action:          aoebot.NewActionEnvelope(action),
 	}
-
-	return env.Bot.Driver.ConditionAdd(cond, env.Author.String())
+	if err := setPhrase(cond, args); err != nil {
+		return err
+	}
+	return addMeme(env, cond, args)
 }
 
 func (a *AddWrite) Ack(env *aoebot.Environment) string {
@@ -95,11 +93,19 @@ type DelWrite struct {
 }
 
 func (d *DelWrite) Name() string {
-	return strings.Fields(d.Usage())[0]
+	return "write"
+}
+
+func (d *DelWrite) Aliases() []string {
+	return []string{"delwrite"}
 }
 
-func (d *DelWrite) Usage() string {
-	return `delwrite "[response]" on "[phrase]"`
+func (d *DelWrite) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Flags:     append([]aoebot.Flag{regexFlag, matchFlag}, delMemeFlags...),
+		Args:      memeArgs("response", aoebot.ArgPhrase),
+		Exclusive: memeExclusive,
+	}
 }
 
 func (d *DelWrite) Short() string {
@@ -107,38 +113,52 @@ func (d *DelWrite) Short() string {
 }
 
 func (d *DelWrite) Long() string {
-	return `Remove an automatic response created by addwrite.`
+	return `Remove an automatic response created by meme add write.
+Use the same [-match] mode that was used to create the response.
+Use the [-regex] flag if [phrase] should be treated as a regular expression.
+Use the [-or] flag to remove an alternative added with meme add write -or, or the [-then] flag to remove a step added with meme add write -then.`
 }
 
 func (d *DelWrite) Examples() []string {
 	return []string{
-		`delwrite "who's there?" on "hello"`,
+		`meme del write "who's there?" on "hello"`,
 	}
 }
 
-func (d *DelWrite) Run(env *aoebot.Environment, args []string) error {
+func (d *DelWrite) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
 	if env.Guild == nil {
 		return errors.New("No guild")
 	}
 
-	argString := strings.Join(args, " ")
-	response, phrase, err := parseWriteCmd(argString, d.Usage())
+	action, err := writeAction(args)
 	if err != nil {
 		return err
 	}
-
 	cond := &aoebot.Condition{
 		EnvironmentType: aoebot.Message,
 		GuildID:         env.Guild.ID,
-		Phrase:          phrase,
-		Action: aoebot.NewActionEnvelope(&aoebot.WriteAction{
-			Content: response,
-		}),
+		Action:          aoebot.NewActionEnvelope(action),
 	}
-
-	return env.Bot.Driver.ConditionDisable(cond)
+	if err := setPhrase(cond, args); err != nil {
+		return err
+	}
+	return delMeme(env, cond, args)
 }
 
 func (a *DelWrite) Ack(env *aoebot.Environment) string {
 	return "🗑"
 }
+
+// writeAction is the response of an meme add write or meme del write command
+func writeAction(args *aoebot.Args) (*aoebot.WriteAction, error) {
+	action := &aoebot.WriteAction{
+		Content: args.String("response"),
+	}
+	if action.Content == "" {
+		return nil, errors.New("Couldn't parse response")
+	}
+	if _, err := action.ParseTemplate(); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
@@ -7,7 +7,6 @@ import (
 	"text/tabwriter"
 
 	"github.com/jeffreymkabot/aoebot"
-	"gopkg.in/mgo.v2/bson"
 )
 
 type Aoe2 struct {
@@ -35,15 +34,7 @@ func (a *Aoe2) Long() string {
 }
 
 func (a *Aoe2) Run(env *aoebot.Environment, args []string) error {
-	conditions := []aoebot.Condition{}
-	coll := env.Bot.Driver.DB("aoebot").C("conditions")
-	query := bson.M{
-		"tags": "aoe2",
-	}
-	err := coll.Find(query).All(&conditions)
-	if err != nil {
-		return err
-	}
+	conditions := env.Bot.Driver.ConditionsTagged("aoe2")
 	if len(conditions) == 0 {
 		return nil
 	}
@@ -54,5 +45,5 @@ func (a *Aoe2) Run(env *aoebot.Environment, args []string) error {
 		fmt.Fprintf(w, "%s    \t%s\n", c.Phrase, c.Name)
 	}
 	fmt.Fprintf(w, "```\n")
-	return env.Bot.Write(env.TextChannel.ID, buf.String(), false)
+	return env.Reply(buf.String())
 }
@@ -0,0 +1,377 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jeffreymkabot/aoebot"
+)
+
+// the aoe2 taunts and the clips uploaded before the clip library are kept in the same directory
+var audioDir = filepath.Dir(voiceFilePathTmpl)
+
+const clipExt = ".dca"
+
+// each frame of a dca file is this long, see dcaFromURL
+const clipFrameDuration = 20 * time.Millisecond
+
+// discord limits the length of a message
+const maxMessageLength = 2000
+
+// clip is a sound file that can be played
+type clip struct {
+	name    string
+	file    string
+	aliases []string
+	// duration is known for clips in the clip library
+	duration time.Duration
+}
+
+// number is the taunt number at the start of the clip's name, e.g. 14 for "14 start the game"
+func (c clip) number() (int, bool) {
+	fields := strings.Fields(c.name)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(fields[0])
+	return n, err == nil
+}
+
+// guildClips finds the clips in the audio directory and the clips in the clip library said by the memes of a guild,
+// with the aliases given to them by the memes of the guild and the aoe2 taunts
+func guildClips(env *aoebot.Environment) ([]clip, error) {
+	files, err := ioutil.ReadDir(audioDir)
+	if err != nil {
+		return nil, err
+	}
+	clips := []clip{}
+	byFile := make(map[string]int)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), clipExt) {
+			continue
+		}
+		file := filepath.Join(audioDir, f.Name())
+		byFile[file] = len(clips)
+		clips = append(clips, clip{
+			name: strings.TrimSuffix(f.Name(), clipExt),
+			file: file,
+		})
+	}
+
+	conds := env.Bot.Driver.ConditionsTagged("aoe2")
+	if env.Guild != nil {
+		conds = append(conds, env.Bot.Driver.ConditionsGuild(env.Guild.ID)...)
+	}
+	for _, c := range conds {
+		for _, va := range aoebot.VoiceActions(c.Action.Action) {
+			file := filepath.Clean(va.Path())
+			i, ok := byFile[file]
+			if !ok && va.Clip != "" {
+				i, ok = len(clips), true
+				byFile[file] = i
+				clips = append(clips, libraryClip(env, va))
+			}
+			if !ok || va.Alias == "" || strings.EqualFold(va.Alias, clips[i].name) || hasAlias(clips[i], va.Alias) {
+				continue
+			}
+			clips[i].aliases = append(clips[i].aliases, va.Alias)
+		}
+	}
+	return clips, nil
+}
+
+// libraryClip describes a clip in the clip library by its record
+func libraryClip(env *aoebot.Environment, va *aoebot.VoiceAction) clip {
+	c := clip{name: va.Alias, file: va.Path()}
+	if record, err := env.Bot.Driver.Clip(va.Clip); err == nil {
+		c.name = record.Name
+		c.duration = record.Duration
+	}
+	if c.name == "" {
+		c.name = va.Clip
+	}
+	return c
+}
+
+func hasAlias(c clip, alias string) bool {
+	for _, a := range c.aliases {
+		if strings.EqualFold(a, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// findClip resolves a clip by its name or an alias, then by its taunt number, then by the clips whose names contain every word of the query
+func findClip(clips []clip, query string) (clip, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return clip{}, errors.New("Which clip?")
+	}
+	for _, c := range clips {
+		if strings.ToLower(c.name) == query || hasAlias(c, query) {
+			return c, nil
+		}
+	}
+	if n, err := strconv.Atoi(query); err == nil {
+		for _, c := range clips {
+			if m, ok := c.number(); ok && m == n {
+				return c, nil
+			}
+		}
+		return clip{}, fmt.Errorf("I don't have a clip number %v", n)
+	}
+
+	matches := []clip{}
+	for _, c := range clips {
+		if containsWords(c, strings.Fields(query)) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return clip{}, fmt.Errorf("I don't have a clip like %q", query)
+	}
+	// the clip with the shortest name has the least that was not asked for
+	sort.SliceStable(matches, func(i, j int) bool {
+		return len(matches[i].name) < len(matches[j].name)
+	})
+	return matches[0], nil
+}
+
+// containsWords is true when the name or an alias of a clip contains every word
+func containsWords(c clip, words []string) bool {
+	for _, name := range append([]string{c.name}, c.aliases...) {
+		name = strings.ToLower(name)
+		all := true
+		for _, w := range words {
+			if !strings.Contains(name, w) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// clipDuration counts the frames of a dca file
+// Each frame is a little endian int16 length followed by that many bytes of opus data
+func clipDuration(file string) (time.Duration, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	frames := 0
+	var length int16
+	for {
+		err = binary.Read(f, binary.LittleEndian, &length)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if length < 0 {
+			return 0, fmt.Errorf("Bad frame in %v", file)
+		}
+		if _, err = f.Seek(int64(length), io.SeekCurrent); err != nil {
+			return 0, err
+		}
+		frames++
+	}
+	return time.Duration(frames) * clipFrameDuration, nil
+}
+
+type Play struct {
+	aoebot.BaseCommand
+}
+
+func (p *Play) Name() string {
+	return "play"
+}
+
+func (p *Play) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Args: []aoebot.Arg{
+			{Name: "clip", Variadic: true},
+		},
+	}
+}
+
+func (p *Play) Short() string {
+	return `Say a sound clip in your voice channel`
+}
+
+func (p *Play) Long() string {
+	return `Say [clip] to the voice channel you are connected to.
+[clip] can be the name of a clip, an alias given to it by a meme, the number of an aoe2 taunt, or a few words from its name.
+Use the clips command to list the clips I have.`
+}
+
+func (p *Play) Examples() []string {
+	return []string{
+		`play 14`,
+		`play wololo`,
+		`play start game`,
+		`play greenhillzone.wav`,
+	}
+}
+
+func (p *Play) Complete(env *aoebot.Environment, option string, partial string) []string {
+	clips, err := guildClips(env)
+	if err != nil {
+		return nil
+	}
+	partial = strings.ToLower(strings.TrimSpace(partial))
+	names := []string{}
+	for _, c := range clips {
+		if containsWords(c, strings.Fields(partial)) {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+func (p *Play) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	clips, err := guildClips(env)
+	if err != nil {
+		return err
+	}
+	c, err := findClip(clips, strings.Join(args.Strings("clip"), " "))
+	if err != nil {
+		return err
+	}
+	payload, err := ioutil.ReadFile(c.file)
+	if err != nil {
+		return err
+	}
+	return env.Bot.SayToUser(env.Guild, env.Author.ID, c.name, bytes.NewReader(payload))
+}
+
+func (p *Play) Ack(env *aoebot.Environment) string {
+	return "🔊"
+}
+
+type Clips struct {
+	aoebot.BaseCommand
+}
+
+func (c *Clips) Name() string {
+	return strings.Fields(c.Usage())[0]
+}
+
+func (c *Clips) Usage() string {
+	return `clips`
+}
+
+func (c *Clips) Short() string {
+	return `List the sound clips I can play`
+}
+
+func (c *Clips) Long() string {
+	return `List the sound clips I can play with the play command, how long they are, and their aliases.`
+}
+
+func (c *Clips) Run(env *aoebot.Environment, args []string) error {
+	clips, err := guildClips(env)
+	if err != nil {
+		return err
+	}
+	if len(clips) == 0 {
+		return errors.New("no clips 😦")
+	}
+	lines := []string{}
+	for _, c := range clips {
+		duration := "?"
+		if c.duration > 0 {
+			duration = fmt.Sprintf("%.1fs", c.duration.Seconds())
+		} else if d, err := clipDuration(c.file); err == nil {
+			duration = fmt.Sprintf("%.1fs", d.Seconds())
+		}
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s", c.name, duration, strings.Join(c.aliases, ", ")))
+	}
+	for _, page := range clipPages(lines) {
+		if err := env.Reply(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clipPages lines up the columns of lines and splits them into code blocks that each fit in a message
+func clipPages(lines []string) []string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+
+	const fence = "```"
+	pages := []string{}
+	page := &bytes.Buffer{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		// the last column is often empty
+		line = strings.TrimRight(line, " ") + "\n"
+		if page.Len() > 0 && page.Len()+len(line)+2*len(fence)+1 > maxMessageLength {
+			pages = append(pages, fence+"\n"+page.String()+fence)
+			page.Reset()
+		}
+		page.WriteString(line)
+	}
+	if page.Len() > 0 {
+		pages = append(pages, fence+"\n"+page.String()+fence)
+	}
+	return pages
+}
+
+type PruneClips struct {
+	aoebot.BaseCommand
+}
+
+func (p *PruneClips) Name() string {
+	return strings.Fields(p.Usage())[0]
+}
+
+func (p *PruneClips) Usage() string {
+	return `pruneclips`
+}
+
+func (p *PruneClips) Short() string {
+	return `Delete clips that no meme says`
+}
+
+func (p *PruneClips) Long() string {
+	return `Delete the files in the clip library that are not said by any meme in any guild, and forget their records.
+Files uploaded in the last hour are kept in case their meme is still being made.`
+}
+
+func (p *PruneClips) IsOwnerOnly() bool {
+	return true
+}
+
+func (p *PruneClips) Run(env *aoebot.Environment, args []string) error {
+	files, size, err := env.Bot.Driver.PruneClips()
+	if err != nil {
+		return err
+	}
+	return env.Reply(fmt.Sprintf("Deleted %d files, %.1f KB 🧹", files, float64(size)/1024))
+}
@@ -0,0 +1,779 @@
+package commands_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jeffreymkabot/aoebot"
+	"github.com/jeffreymkabot/aoebot/commands"
+	"github.com/jeffreymkabot/aoebot/fake"
+)
+
+// the users that write commands in the tests
+var (
+	bob   = &discordgo.User{ID: "u", Username: "bob", Discriminator: "0001"}
+	eve   = &discordgo.User{ID: "v", Username: "eve", Discriminator: "0002"}
+	owner = &discordgo.User{ID: "owner", Username: "boss", Discriminator: "0003"}
+)
+
+// the aoe2 taunt that the tests play, which is a second long
+var taunt = &aoebot.Condition{
+	EnvironmentType: aoebot.Message,
+	Phrase:          "14",
+	Tags:            []string{"aoe2"},
+	Action: aoebot.NewActionEnvelope(&aoebot.VoiceAction{
+		File:  "media/audio/14 start the game.dca",
+		Alias: "start the game",
+	}),
+}
+
+// TestMain runs the tests in a directory with the media the commands read
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	dir, err := ioutil.TempDir("", "commands")
+	if err != nil {
+		log.Fatal(err)
+	}
+	code := func() int {
+		defer os.RemoveAll(dir)
+		if err := os.Chdir(dir); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeClip("media/audio/14 start the game.dca", 50); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeClip("media/audio/40 enemy.dca", 25); err != nil {
+			log.Fatal(err)
+		}
+		return m.Run()
+	}()
+	os.Exit(code)
+}
+
+// writeClip writes a dca file with some frames of silence
+func writeClip(path string, frames int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	frame := []byte{0xf8, 0xff, 0xfe}
+	for i := 0; i < frames; i++ {
+		binary.Write(buf, binary.LittleEndian, int16(len(frame)))
+		buf.Write(frame)
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// newBot starts a bot with every command in a guild where bob is the owner and eve is a member
+func newBot(t *testing.T) (*aoebot.Bot, *fake.Session) {
+	s := fake.NewSession(&discordgo.User{ID: "bot", Username: "aoebot", Bot: true})
+	s.AddUser(owner)
+	err := s.AddGuild(&discordgo.Guild{
+		ID:              "g",
+		Name:            "guild",
+		OwnerID:         bob.ID,
+		SystemChannelID: "c",
+		Channels: []*discordgo.Channel{
+			{ID: "c", Name: "general", Type: discordgo.ChannelTypeGuildText},
+		},
+		Members: []*discordgo.Member{
+			{User: bob},
+			{User: eve},
+		},
+		Roles: []*discordgo.Role{
+			{ID: "g", Name: "@everyone"},
+			{ID: "42", Name: "mods"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := aoebot.New("", aoebot.DialBolt(filepath.Join(t.TempDir(), "aoebot.db")), owner.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.WithSession(s, s.State)
+	b.AddCommand(
+		&commands.Aoe2{},
+		&commands.Play{},
+		&commands.Clips{},
+		&commands.PruneClips{},
+		commands.Meme(),
+		&commands.AddChannel{},
+		&commands.Welcome{},
+		&commands.Farewell{},
+		commands.Game(),
+		commands.Voice(),
+		&commands.Prefix{},
+		&commands.Roll{},
+		&commands.Source{},
+		&commands.TestAction{},
+	)
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Driver.ConditionAdd(taunt, "aoe2"); err != nil {
+		t.Fatal(err)
+	}
+	return b, s
+}
+
+var lastMessageID = 0
+
+// write emits a message written to the guild's channel
+func write(s *fake.Session, author *discordgo.User, content string, attachments ...*discordgo.MessageAttachment) {
+	lastMessageID++
+	m := &discordgo.Message{
+		ID:          "m" + strconv.Itoa(lastMessageID),
+		ChannelID:   "c",
+		Content:     content,
+		Author:      author,
+		Attachments: attachments,
+	}
+	s.State.MessageAdd(m)
+	s.Emit(&discordgo.MessageCreate{Message: m})
+}
+
+// activity counts the messages sent and reactions added by the bot
+func activity(s *fake.Session) int {
+	return len(s.Sent("c")) + len(s.Reactions)
+}
+
+// waitFor waits until done is true, or fails the test
+func waitFor(t *testing.T, s *fake.Session, what string, done func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %v, sent %q and reacted %v", what, s.Sent("c"), s.Reactions)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type commandTest struct {
+	name  string
+	setup func(b *aoebot.Bot)
+	// before are messages written by bob before the input, each of which the bot answers
+	before []string
+	// event is emitted instead of writing the input, if it is set
+	event       interface{}
+	author      *discordgo.User
+	input       string
+	attachments []*discordgo.MessageAttachment
+	// replies are in the messages sent after the input, in order
+	replies []string
+	// reactions are the emoji added to messages after the input, in order
+	reactions []string
+	// roles are the roles given to or taken from bob after the input, by name
+	roles []string
+	check func(t *testing.T, b *aoebot.Bot)
+}
+
+func TestCommands(t *testing.T) {
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	upload := []*discordgo.MessageAttachment{{ID: "a", Filename: "skrrt.wav", URL: missing.URL + "/skrrt.wav"}}
+
+	tests := []commandTest{
+		{
+			name:    "aoe2 lists the taunts",
+			input:   "@!aoe2",
+			replies: []string{"14"},
+		},
+		{
+			name:    "play finds a taunt by number",
+			input:   "@!play 14",
+			replies: []string{"Couldn't find user u in a voice channel"},
+		},
+		{
+			name:    "play does not find an unknown clip",
+			input:   "@!play 99",
+			replies: []string{"I don't have a clip number 99"},
+		},
+		{
+			name:    "clips lists the clips with their durations and aliases",
+			input:   "@!clips",
+			replies: []string{"14 start the game  1.0s  start the game"},
+		},
+		{
+			name:    "pruneclips deletes nothing without a clip library",
+			author:  owner,
+			input:   "@!pruneclips",
+			replies: []string{"Deleted 0 files, 0.0 KB"},
+		},
+		{
+			name:    "pruneclips is only for the owner",
+			input:   "@!pruneclips",
+			replies: []string{"I'm afraid I can't do that"},
+		},
+		{
+			name:    "meme list is empty",
+			input:   "@!meme list",
+			replies: []string{"no memes"},
+		},
+		{
+			name:    "meme list shows the memes of the guild",
+			before:  []string{`@!meme add write pong on ping`},
+			input:   "@!meme list",
+			replies: []string{"pong"},
+		},
+		{
+			name:      "meme add write",
+			input:     `@!meme add write "hi {{.Author.Username}}" on hello`,
+			reactions: []string{"✅"},
+		},
+		{
+			name:    "meme add write rejects a bad template",
+			input:   `@!meme add write "hi {{.Author" on hello`,
+			replies: []string{"template"},
+		},
+		{
+			name:    "a written meme responds to its phrase",
+			before:  []string{`@!meme add write "hi {{.Author.Username}}" on hello`},
+			input:   "Hello",
+			replies: []string{"hi bob"},
+		},
+		{
+			name:      "meme del write",
+			before:    []string{`@!meme add write pong on ping`},
+			input:     `@!meme del write pong on ping`,
+			reactions: []string{"🗑"},
+			check:     memes(0),
+		},
+		{
+			name:   "a deleted meme does not respond",
+			before: []string{`@!meme add write pong on ping`, `@!meme del write pong on ping`},
+			input:  "ping",
+		},
+		{
+			name:    "a regex meme echoes its captures as they were written",
+			before:  []string{`@!meme add write -regex "{{index .Captures 1}}? I hardly know her" on "(\w+)er$"`},
+			input:   "Butter",
+			replies: []string{"Butt? I hardly know her"},
+		},
+		{
+			name:    "meme list shows the regex of a written meme",
+			before:  []string{`@!meme add write -regex pong on "^p(i|o)ng$"`},
+			input:   "@!meme list",
+			replies: []string{"write -regex \"`pong`\" on `(?i)^p(i|o)ng$`"},
+		},
+		{
+			name:      "meme del write -regex",
+			before:    []string{`@!meme add write -regex pong on "^p(i|o)ng$"`},
+			input:     `@!meme del write -regex pong on "^p(i|o)ng$"`,
+			reactions: []string{"🗑"},
+			check:     memes(0),
+		},
+		{
+			name: "meme del write -regex finds a regex saved before regexes ignored case",
+			setup: func(b *aoebot.Bot) {
+				b.Driver.ConditionAdd(&aoebot.Condition{
+					EnvironmentType: aoebot.Message,
+					GuildID:         "g",
+					RegexPhrase:     "^p(i|o)ng$",
+					Action:          aoebot.NewActionEnvelope(&aoebot.WriteAction{Content: "pong"}),
+				}, bob.String())
+			},
+			before:    []string{"PING"},
+			input:     `@!meme del write -regex pong on "^P(i|o)ng$"`,
+			reactions: []string{"🗑"},
+			check:     memes(0),
+		},
+		{
+			name:      "meme add write -or replaces the meme",
+			before:    []string{`@!meme add write pong on ping`, `@!meme add write -or pang on ping`},
+			input:     `@!meme add write -or pung on ping`,
+			reactions: []string{"✅"},
+			check:     memeActions("pong | pang | pung"),
+		},
+		{
+			name:    "a meme with alternatives responds once",
+			before:  []string{`@!meme add write pong on ping`, `@!meme add write -or pang on ping`, `@!meme add write -or pung on ping`},
+			input:   "ping",
+			replies: []string{"p"},
+		},
+		{
+			name:      "meme del write -or replaces the meme",
+			before:    []string{`@!meme add write pong on ping`, `@!meme add write -or pang on ping`, `@!meme add write -or pung on ping`},
+			input:     `@!meme del write -or pang on ping`,
+			reactions: []string{"🗑"},
+			check:     memeActions("pong | pung"),
+		},
+		{
+			name:      "meme add write -then replaces the meme",
+			before:    []string{`@!meme add write one on count`, `@!meme add write -then two on count`},
+			input:     `@!meme add write -then three on count`,
+			reactions: []string{"✅"},
+			check:     memeActions("one then two then three"),
+		},
+		{
+			name:    "a meme with steps responds with each step once",
+			before:  []string{`@!meme add write one on count`, `@!meme add write -then two on count`, `@!meme add write -then three on count`},
+			input:   "count",
+			replies: []string{"one", "two", "three"},
+		},
+		{
+			name: "meme add write -then fits in the limit on memes",
+			setup: func(b *aoebot.Bot) {
+				cfg := b.Config
+				cfg.MaxManagedConditions = 1
+				b.WithConfig(cfg)
+			},
+			before:  []string{`@!meme add write one on count`, `@!meme add write -then two on count`},
+			input:   `@!meme add write three on three`,
+			replies: []string{"I'm not allowed make any more memes in this guild"},
+			check:   memeActions("one then two"),
+		},
+		{
+			name:    "a meme with a cooldown does not respond again right away",
+			before:  []string{`@!meme add write -cooldown 1m pong on ping`, "ping"},
+			input:   "ping",
+			replies: []string{},
+		},
+		{
+			name:    "a meme with a cooldown per user responds to someone else",
+			before:  []string{`@!meme add write -cooldown 1m -per user pong on ping`, "ping"},
+			author:  eve,
+			input:   "ping",
+			replies: []string{"pong"},
+		},
+		{
+			name:    "meme add write rejects an unknown cooldown scope",
+			input:   `@!meme add write -cooldown 1m -per guild pong on ping`,
+			replies: []string{"-per should be one of"},
+			check:   memes(0),
+		},
+		{
+			name:    "meme add write -chance rejects more than 100 percent",
+			input:   `@!meme add write -chance 101 pong on ping`,
+			replies: []string{"Chance should be a percent between 1 and 100, not 101"},
+			check:   memes(0),
+		},
+		{
+			name:    "a meme with a chance of 100 percent always responds",
+			before:  []string{`@!meme add write -chance 100 pong on ping`},
+			input:   "ping",
+			replies: []string{"pong"},
+		},
+		{
+			name:      "meme add react tries the emoji",
+			input:     `@!meme add react 👋 on hi`,
+			reactions: []string{"👋", "✅"},
+			check:     memes(1),
+		},
+		{
+			name:      "a react meme reacts to its phrase",
+			before:    []string{`@!meme add react 👋 on hi`},
+			input:     "hi",
+			reactions: []string{"👋"},
+		},
+		{
+			name:      "meme del react",
+			before:    []string{`@!meme add react 👋 on hi`},
+			input:     `@!meme del react 👋 on hi`,
+			reactions: []string{"🗑"},
+			check:     memes(0),
+		},
+		{
+			name:    "meme add voice needs a file",
+			input:   `@!meme add voice on skrrt`,
+			replies: []string{"No attached file"},
+		},
+		{
+			name:        "meme add voice downloads the file",
+			input:       `@!meme add voice on skrrt`,
+			attachments: upload,
+			replies:     []string{"Couldn't download the file: 404 Not Found"},
+			check:       memes(0),
+		},
+		{
+			name:        "meme add voice only starts at whole seconds",
+			input:       `@!meme add voice -start 1.5s on skrrt`,
+			attachments: upload,
+			replies:     []string{"Start has to be a whole number of seconds"},
+		},
+		{
+			name:    "meme edit voice does not find an unknown clip",
+			input:   `@!meme edit voice skrrt.wav`,
+			replies: []string{`I don't have a clip "skrrt.wav" in this guild`},
+		},
+		{
+			name:    "meme edit voice asks which clip a filename uploaded twice is",
+			setup:   twoUploads,
+			input:   "@!meme edit voice skrrt.wav",
+			replies: []string{"skrrt.wav was uploaded more than once, so which clip do you mean?\n`aaa` said by 1 memes\n`bbb` said by 1 memes"},
+		},
+		{
+			name:    "meme edit voice finds a clip by its id",
+			setup:   twoUploads,
+			input:   "@!meme edit voice bbb",
+			replies: []string{"I didn't keep the file bbb was made from"},
+		},
+		{
+			name:    "meme edit voice checks the volume",
+			input:   `@!meme edit voice -volume 100 skrrt.wav`,
+			replies: []string{"Volume should be between"},
+		},
+		{
+			name: "meme del voice",
+			setup: func(b *aoebot.Bot) {
+				b.Driver.ConditionAdd(&aoebot.Condition{
+					EnvironmentType: aoebot.Message,
+					GuildID:         "g",
+					Phrase:          "skrrt",
+					Action:          aoebot.NewActionEnvelope(&aoebot.VoiceAction{Clip: "abc", Alias: "skrrt.wav"}),
+				}, bob.String())
+			},
+			input:     `@!meme del voice skrrt.wav on skrrt`,
+			reactions: []string{"🗑"},
+			check:     memes(0),
+		},
+		{
+			name:      "addchannel makes a voice channel",
+			input:     "@!addchannel -users 4",
+			reactions: []string{"✅"},
+			check: func(t *testing.T, b *aoebot.Bot) {
+				if n := len(b.Driver.ChannelsGuild("g")); n != 1 {
+					t.Errorf("Made %v channels", n)
+				}
+			},
+		},
+		{
+			name:      "welcome",
+			input:     `@!welcome "welcome {{.Author.Username}}"`,
+			reactions: []string{"✅"},
+		},
+		{
+			name:    "welcome greets a member who joins",
+			before:  []string{`@!welcome "welcome {{.Author.Username}}"`},
+			event:   &discordgo.GuildMemberAdd{Member: &discordgo.Member{GuildID: "g", User: &discordgo.User{ID: "n", Username: "newbie"}}},
+			replies: []string{"welcome newbie"},
+		},
+		{
+			name:    "welcome needs a message or the off flag",
+			input:   `@!welcome`,
+			replies: []string{"Use either the [-off] flag or a message"},
+		},
+		{
+			name:    "farewell says goodbye to a member who leaves",
+			before:  []string{`@!farewell "bye {{.Author.Username}}"`},
+			event:   &discordgo.GuildMemberRemove{Member: &discordgo.Member{GuildID: "g", User: eve}},
+			replies: []string{"bye eve"},
+		},
+		{
+			name:      "game add",
+			input:     "@!game add overwatch ow",
+			reactions: []string{"✅"},
+		},
+		{
+			name:    "game list",
+			before:  []string{"@!game add overwatch ow"},
+			input:   "@!game list",
+			replies: []string{"overwatch"},
+		},
+		{
+			name:      "game join gives a role for the game",
+			setup:     guildPrefs,
+			before:    []string{"@!game add overwatch ow"},
+			input:     "@!game join ow",
+			reactions: []string{"🆗"},
+			roles:     []string{"+overwatch"},
+		},
+		{
+			name:    "game join does not know every game",
+			setup:   guildPrefs,
+			input:   "@!game join ow",
+			replies: []string{"I haven't heard of ow", "no games"},
+		},
+		{
+			name:      "game leave takes the role for the game",
+			setup:     guildPrefs,
+			before:    []string{"@!game add overwatch ow", "@!game join ow"},
+			input:     "@!game leave overwatch",
+			reactions: []string{"🆗"},
+			roles:     []string{"-overwatch"},
+		},
+		{
+			name:    "voice queue is empty",
+			input:   "@!voice queue",
+			replies: []string{"Nothing is queued"},
+		},
+		{
+			name:    "voice skip needs a voicebox",
+			input:   "@!voice skip",
+			replies: []string{"No voicebox registered for this guild"},
+		},
+		{
+			name:    "voice clear needs a voicebox",
+			input:   "@!voice clear",
+			replies: []string{"No voicebox registered for this guild"},
+		},
+		{
+			name:    "voice stop needs a voicebox",
+			input:   "@!voice stop",
+			replies: []string{"No voicebox registered for this guild"},
+		},
+		{
+			name:      "prefix shows the prefix",
+			input:     "@!prefix",
+			replies:   []string{"My commands start with `@!` in this guild"},
+			reactions: []string{"✅"},
+		},
+		{
+			name:      "prefix changes the prefix",
+			before:    []string{"@!prefix !aoe"},
+			input:     "!aoe prefix",
+			replies:   []string{"My commands start with `!aoe` in this guild"},
+			reactions: []string{"✅"},
+		},
+		{
+			name:      "prefix -reset restores the default prefix",
+			before:    []string{"@!prefix !aoe", "!aoe prefix -reset"},
+			input:     "@!prefix",
+			replies:   []string{"My commands start with `@!` in this guild"},
+			reactions: []string{"✅"},
+		},
+		{
+			name:    "prefix is only for admins",
+			author:  eve,
+			input:   "@!prefix !aoe",
+			replies: []string{"I'm afraid I can't do that"},
+		},
+		{
+			name:      "help -here lists the commands",
+			input:     "@!help -here",
+			replies:   []string{"meme add write"},
+			reactions: []string{"📬"},
+		},
+		{
+			name:      "help -here explains a command",
+			input:     "@!help -here prefix",
+			replies:   []string{"prefix [-reset] [prefix]"},
+			reactions: []string{"📬"},
+		},
+		{
+			name:      "perms shows that anyone can run the commands",
+			input:     "@!perms",
+			replies:   []string{"Anyone can run my commands in this guild"},
+			reactions: []string{"✅"},
+		},
+		{
+			name:      "perms -allow limits a command to a role",
+			input:     "@!perms -allow <@&42> roll",
+			reactions: []string{"✅"},
+			check: func(t *testing.T, b *aoebot.Bot) {
+				prefs, err := b.Driver.GuildPrefs("g")
+				if err != nil || strings.Join(prefs.Permissions["roll"], " ") != "42" {
+					t.Errorf("Guild has rules %v, %v", prefs, err)
+				}
+			},
+		},
+		{
+			name:      "perms shows the roles that can run a command",
+			before:    []string{"@!perms -allow <@&42> roll"},
+			input:     "@!perms roll",
+			replies:   []string{"`roll` can be run by admins and mods"},
+			reactions: []string{"✅"},
+		},
+		{
+			name:    "a member without the role can not run the command",
+			before:  []string{"@!perms -allow <@&42> roll"},
+			author:  eve,
+			input:   "@!roll d1",
+			replies: []string{"I'm afraid I can't do that"},
+		},
+		{
+			name:    "perms -reset lets anyone run the command",
+			before:  []string{"@!perms -allow <@&42> roll", "@!perms -reset roll"},
+			author:  eve,
+			input:   "@!roll d1",
+			replies: []string{"eve rolled 1 on a d1."},
+		},
+		{
+			name:    "perms can not give a command for admins to a role",
+			input:   "@!perms -allow <@&42> prefix",
+			replies: []string{"Only admins can run prefix"},
+		},
+		{
+			name: "a rule does not let a role run a command for admins",
+			setup: func(b *aoebot.Bot) {
+				b.Driver.GuildPrefsSet(&aoebot.GuildPrefs{GuildID: "g", Permissions: map[string][]string{"prefix": {"42"}}})
+				member, _ := b.State.Member("g", eve.ID)
+				member.Roles = []string{"42"}
+			},
+			author:  eve,
+			input:   "@!prefix !aoe",
+			replies: []string{"I'm afraid I can't do that"},
+		},
+		{
+			name:    "perms is only for admins to change",
+			author:  eve,
+			input:   "@!perms -allow <@&42> roll",
+			replies: []string{"Only members who can manage this guild can change who can run my commands"},
+		},
+		{
+			name:  "slash commands are not registered again when a guild reconnects",
+			event: &discordgo.GuildCreate{Guild: &discordgo.Guild{ID: "g", Name: "guild"}},
+			check: func(t *testing.T, b *aoebot.Bot) {
+				for _, r := range b.Session.(*fake.Session).Requests {
+					if r.Method == "PUT" {
+						t.Errorf("Registered slash commands again with %v %v", r.Method, r.URL)
+					}
+				}
+			},
+		},
+		{
+			name:    "roll",
+			input:   "@!roll d1",
+			replies: []string{"bob rolled 1 on a d1."},
+		},
+		{
+			name:    "source",
+			input:   "@!source",
+			replies: []string{"https://github.com/jeffreymkabot/aoebot"},
+		},
+		{
+			name:      "testaction",
+			input:     "@!testaction",
+			reactions: []string{"🤖"},
+			replies:   []string{"Hello World", "Couldn't find user u in a voice channel"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, s := newBot(t)
+			defer b.Stop()
+			if tt.setup != nil {
+				tt.setup(b)
+			}
+
+			for _, line := range tt.before {
+				n := activity(s)
+				write(s, bob, line)
+				waitFor(t, s, line, func() bool { return activity(s) > n })
+			}
+			s.Reset()
+
+			author := tt.author
+			if author == nil {
+				author = bob
+			}
+			if tt.event != nil {
+				s.Emit(tt.event)
+			} else {
+				write(s, author, tt.input, tt.attachments...)
+			}
+			waitFor(t, s, tt.input, func() bool {
+				return len(s.Sent("c")) >= len(tt.replies) && len(s.Reactions) >= len(tt.reactions) && len(s.RoleChanges) >= len(tt.roles)
+			})
+			// anything else the bot does happens right away
+			time.Sleep(50 * time.Millisecond)
+
+			sent := text(s.Messages)
+			if len(sent) != len(tt.replies) {
+				t.Errorf("Sent %q, want %q", sent, tt.replies)
+			} else {
+				for i := range sent {
+					if !strings.Contains(sent[i], tt.replies[i]) {
+						t.Errorf("Sent %q, want %q", sent[i], tt.replies[i])
+					}
+				}
+			}
+			reactions := []string{}
+			for _, r := range s.Reactions {
+				reactions = append(reactions, r.Emoji)
+			}
+			if strings.Join(reactions, " ") != strings.Join(tt.reactions, " ") {
+				t.Errorf("Reacted %q, want %q", reactions, tt.reactions)
+			}
+			roles := []string{}
+			for _, rc := range s.RoleChanges {
+				change := "+"
+				if rc.Removed {
+					change = "-"
+				}
+				if rc.UserID != bob.ID {
+					change += rc.UserID + ":"
+				}
+				if role, err := s.State.Role(rc.GuildID, rc.RoleID); err == nil {
+					change += role.Name
+				}
+				roles = append(roles, change)
+			}
+			if strings.Join(roles, " ") != strings.Join(tt.roles, " ") {
+				t.Errorf("Changed roles %q, want %q", roles, tt.roles)
+			}
+			if tt.check != nil {
+				tt.check(t, b)
+			}
+		})
+	}
+}
+
+// text is what a user reads in each message, including its embeds
+func text(messages []*discordgo.Message) []string {
+	texts := []string{}
+	for _, m := range messages {
+		lines := []string{m.Content}
+		for _, e := range m.Embeds {
+			lines = append(lines, e.Title, e.Description)
+			for _, f := range e.Fields {
+				lines = append(lines, f.Name, f.Value)
+			}
+		}
+		texts = append(texts, strings.Join(lines, "\n"))
+	}
+	return texts
+}
+
+// guildPrefs saves empty preferences for the guild, which some commands need
+func guildPrefs(b *aoebot.Bot) {
+	b.Driver.GuildPrefsSet(&aoebot.GuildPrefs{GuildID: "g"})
+}
+
+// twoUploads adds memes that say two clips uploaded with the same filename
+func twoUploads(b *aoebot.Bot) {
+	for i, id := range []string{"aaa", "bbb"} {
+		b.Driver.ConditionAdd(&aoebot.Condition{
+			EnvironmentType: aoebot.Message,
+			GuildID:         "g",
+			Phrase:          "skrrt" + strconv.Itoa(i),
+			Action:          aoebot.NewActionEnvelope(&aoebot.VoiceAction{Clip: id, Alias: "skrrt.wav"}),
+		}, bob.String())
+	}
+}
+
+// memeActions checks the actions of the memes in the guild
+func memeActions(actions ...string) func(t *testing.T, b *aoebot.Bot) {
+	return func(t *testing.T, b *aoebot.Bot) {
+		got := []string{}
+		for _, c := range b.Driver.ConditionsGuild("g") {
+			got = append(got, fmt.Sprint(c.Action.Action))
+		}
+		if strings.Join(got, "\n") != strings.Join(actions, "\n") {
+			t.Errorf("Guild has memes %q, want %q", got, actions)
+		}
+	}
+}
+
+// memes checks the number of memes in the guild
+func memes(n int) func(t *testing.T, b *aoebot.Bot) {
+	return func(t *testing.T, b *aoebot.Bot) {
+		if got := len(b.Driver.ConditionsGuild("g")); got != n {
+			t.Errorf("Guild has %v memes, want %v", got, n)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/jeffreymkabot/aoebot"
+)
+
+type EditVoice struct {
+	aoebot.BaseCommand
+}
+
+func (e *EditVoice) Name() string {
+	return "voice"
+}
+
+func (e *EditVoice) Aliases() []string {
+	return []string{"editvoice"}
+}
+
+func (e *EditVoice) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Flags: encodeFlags,
+		Args: []aoebot.Arg{
+			{Name: "filename", Type: aoebot.ArgPhrase},
+		},
+	}
+}
+
+// encoding an audio file can take a while
+func (e *EditVoice) Timeout() time.Duration {
+	return 2 * time.Minute
+}
+
+func (e *EditVoice) Short() string {
+	return `Encode a sound clip again with different options`
+}
+
+func (e *EditVoice) Long() string {
+	return `Make a sound clip created by meme add voice again from the file that was uploaded for it.
+Every meme in this guild that says the clip will say the new one, and memes in other guilds keep saying the old one.
+The clip is made with the same defaults as meme add voice, so use every flag you want to keep.
+Use the [-start] flag to skip a whole number of seconds of the audio file and the [-length] flag to take less of it.
+Use the [-volume] flag to make the clip some decibels louder or quieter than usual, e.g. 6 or -6.
+Use the [-af] flag for any other ffmpeg audio filters.
+If more than one clip was uploaded with the same filename, use the id of the clip I show you instead of [filename].
+Clips uploaded before I kept the uploaded files can't be edited, so upload them again instead.`
+}
+
+func (e *EditVoice) Examples() []string {
+	return []string{
+		`meme edit voice -volume -6 "greenhillzone.wav"`,
+		`meme edit voice -start 2s -length 1.5s "greenhillzone.wav"`,
+	}
+}
+
+func (e *EditVoice) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	if err := checkEncode(args); err != nil {
+		return err
+	}
+	filename := args.String("filename")
+	if filename == "" {
+		return errors.New("Couldn't parse filename")
+	}
+
+	id, memes, err := guildClipID(env, filename)
+	if err != nil {
+		return err
+	}
+	record, err := env.Bot.Driver.Clip(id)
+	if err == aoebot.ErrNotFound {
+		record = &aoebot.Clip{Name: filename, GuildID: env.Guild.ID}
+	} else if err != nil {
+		return errors.New("couldn't lookup clip data 😦")
+	}
+	source, err := copySource(id)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("I didn't keep the file %v was made from.  Upload it again with meme add voice instead", record.Name)
+	} else if err != nil {
+		return err
+	}
+	defer os.Remove(source)
+
+	encoded, clip, err := encodeClip(env.Context, source, maxClipDuration(env, args), optionsFromFlags(args)...)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(encoded)
+	// the old clip is kept for the memes of other guilds that say it until it is pruned
+	if err := saveClip(clip.ID, source, encoded); err != nil {
+		return err
+	}
+	clip.Name = record.Name
+	clip.UploadedBy = record.UploadedBy
+	clip.GuildID = record.GuildID
+	if err := env.Bot.Driver.ClipAdd(clip); err != nil {
+		return err
+	}
+	if err := env.Bot.Driver.ConditionsSwapClip(env.Guild.ID, id, clip.ID); err != nil {
+		return err
+	}
+	return env.Reply(fmt.Sprintf("Made a %.1fs clip from %s again for %d memes", clip.Duration.Seconds(), record.Name, memes))
+}
+
+// copySource copies the audio file a clip was made from to a temporary file in the clip library
+func copySource(id string) (string, error) {
+	in, err := os.Open(aoebot.ClipSource(id))
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	f, err := ioutil.TempFile(aoebot.ClipDir, "upload-")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, in)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// guildClipID finds the clip in the clip library that memes in a guild say for a filename or a clip id,
+// and counts those memes
+// a filename is ambiguous when it was uploaded more than once, so those clips have to be picked by id
+func guildClipID(env *aoebot.Environment, name string) (id string, memes int, err error) {
+	legacy := false
+	ids := []string{}
+	counts := make(map[string]int)
+	for _, c := range env.Bot.Driver.ConditionsGuild(env.Guild.ID) {
+		says := make(map[string]bool)
+		for _, va := range aoebot.VoiceActions(c.Action.Action) {
+			if va.Clip == "" {
+				legacy = legacy || va.Alias == name
+				continue
+			}
+			if va.Alias != name && va.Clip != name {
+				continue
+			}
+			if _, ok := counts[va.Clip]; !ok {
+				ids = append(ids, va.Clip)
+			}
+			if !says[va.Clip] {
+				says[va.Clip] = true
+				counts[va.Clip]++
+			}
+		}
+	}
+	switch {
+	case len(ids) > 1:
+		buf := &bytes.Buffer{}
+		fmt.Fprintf(buf, "%v was uploaded more than once, so which clip do you mean?", name)
+		for _, id := range ids {
+			fmt.Fprintf(buf, "\n`%v` said by %d memes", id, counts[id])
+		}
+		return "", 0, errors.New(buf.String())
+	case len(ids) == 1:
+		return ids[0], counts[ids[0]], nil
+	case legacy:
+		return "", 0, fmt.Errorf("%v was uploaded before I kept the uploaded files.  Upload it again with meme add voice instead", name)
+	}
+	return "", 0, fmt.Errorf("I don't have a clip %q in this guild", name)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jeffreymkabot/aoebot"
@@ -23,15 +24,15 @@ func (g *Memes) Name() string {
 }
 
 func (g *Memes) Aliases() []string {
-	return []string{"getmemes", "ls", "listmemes", "list"}
+	return []string{"memes", "getmemes", "ls", "listmemes", "list"}
 }
 
 func (g *Memes) Usage() string {
-	return `memes`
+	return `list`
 }
 
 func (g *Memes) Short() string {
-	return `List actions created by add* commands`
+	return `List actions created by meme add commands`
 }
 
 func (g *Memes) Long() string {
@@ -48,10 +49,10 @@ func (g *Memes) Run(env *aoebot.Environment, args []string) error {
 		return errors.New("no memes")
 	}
 
-	embeds := memesEmbeds(conds)
+	embeds := memesEmbeds(env, conds)
 
 	for _, embed := range embeds {
-		_, err := env.Bot.Session.ChannelMessageSendEmbed(env.TextChannel.ID, embed)
+		err := env.ReplyEmbed(embed)
 		if err != nil {
 			return err
 		}
@@ -60,7 +61,7 @@ func (g *Memes) Run(env *aoebot.Environment, args []string) error {
 	return nil
 }
 
-func memesEmbeds(conds []aoebot.Condition) (embeds []*discordgo.MessageEmbed) {
+func memesEmbeds(env *aoebot.Environment, conds []aoebot.Condition) (embeds []*discordgo.MessageEmbed) {
 	title := strconv.Itoa(len(conds)) + " memes, wow :))"
 
 	for i := 0; i < len(conds); i += memesPerPage {
@@ -70,12 +71,12 @@ func memesEmbeds(conds []aoebot.Condition) (embeds []*discordgo.MessageEmbed) {
 		}
 
 		page := conds[i:end]
-		embeds = append(embeds, memesEmbed(page, title, i))
+		embeds = append(embeds, memesEmbed(env, page, title, i))
 	}
 	return
 }
 
-func memesEmbed(page []aoebot.Condition, title string, offset int) *discordgo.MessageEmbed {
+func memesEmbed(env *aoebot.Environment, page []aoebot.Condition, title string, offset int) *discordgo.MessageEmbed {
 	embed := &discordgo.MessageEmbed{}
 	embed.Title = title
 	embed.Description = strconv.Itoa(offset) + " : " + strconv.Itoa(offset+len(page)-1)
@@ -91,10 +92,29 @@ func memesEmbed(page []aoebot.Condition, title string, offset int) *discordgo.Me
 		field := &discordgo.MessageEmbedField{Name: strconv.Itoa(i + offset)}
 		buf := &bytes.Buffer{}
 		for _, cond := range row {
-			buf.WriteString(cond.GeneratedName() + "\n")
+			buf.WriteString(cond.GeneratedName())
+			if 0 < cond.Chance && cond.Chance < 100 {
+				buf.WriteString(" (" + strconv.Itoa(cond.Chance) + "% chance)")
+			}
+			if cond.Cooldown > 0 {
+				buf.WriteString(" " + cooldownString(env, cond))
+			}
+			buf.WriteString("\n")
 		}
 		field.Value = buf.String()
 		embed.Fields = append(embed.Fields, field)
 	}
 	return embed
 }
+
+// e.g. "(cooldown 30s per user, 12s left)"
+func cooldownString(env *aoebot.Environment, cond aoebot.Condition) string {
+	str := "(cooldown " + cond.Cooldown.String()
+	if cond.CooldownScope != aoebot.CooldownCondition {
+		str += " per " + cond.CooldownScope.String()
+	}
+	if left := env.Bot.CooldownRemaining(env, cond); left > 0 {
+		str += ", " + left.Round(time.Second).String() + " left"
+	}
+	return str + ")"
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"github.com/jeffreymkabot/aoebot"
+)
+
+// Meme groups the commands that list, create, change, and remove memes, e.g. meme add write
+func Meme() *aoebot.Group {
+	return aoebot.NewGroup("meme", "Create, change, and remove memes",
+		&Memes{},
+		aoebot.NewGroup("add", "Create a meme",
+			&AddWrite{},
+			&AddReact{},
+			&AddVoice{},
+		),
+		aoebot.NewGroup("edit", "Change a meme",
+			&EditVoice{},
+		),
+		aoebot.NewGroup("del", "Remove a meme",
+			&DelWrite{},
+			&DelReact{},
+			&DelVoice{},
+		),
+	)
+}
+
+// Game groups the commands that manage game roles, e.g. game join
+func Game() *aoebot.Group {
+	return aoebot.NewGroup("game", "Manage game roles",
+		&AddGame{},
+		&ListGame{},
+		&IPlay{},
+		&IDontPlay{},
+	)
+}
+
+// Voice groups the commands that control what is said to voice channels, e.g. voice skip
+func Voice() *aoebot.Group {
+	return aoebot.NewGroup("voice", "Control what I say in voice channels",
+		&Queue{},
+		&Skip{},
+		&Clear{},
+		&Stop{},
+	)
+}
@@ -18,8 +18,12 @@ func (ip *IPlay) Name() string {
 	return strings.Fields(ip.Usage())[0]
 }
 
+func (ip *IPlay) Aliases() []string {
+	return []string{"iplay"}
+}
+
 func (ip *IPlay) Usage() string {
-	return "iplay [names]..."
+	return "join [names]..."
 }
 
 func (ip *IPlay) Short() string {
@@ -29,16 +33,16 @@ func (ip *IPlay) Short() string {
 func (ip *IPlay) Long() string {
 	return `Give yourself a role that can be mentioned to see who wants to play a game.
 E.g. "Who wants to play @overwatch?"
-Games need to have been registerd using addgame.  You can refer to games by any registered nickname.
+Games need to have been registerd using game add.  You can refer to games by any registered nickname.
 You can give yourself roles for multiple games at the same time.  Separate game names with spaces.
-You can remove a role using idontplay.`
+You can remove a role using game leave.`
 }
 
 func (ip *IPlay) Examples() []string {
 	return []string{
-		"iplay pubg",
-		"iplay counterstrike worldofwarcraft",
-		"iplay csgo wow dota2 overwatch lol smash pubg",
+		"game join pubg",
+		"game join counterstrike worldofwarcraft",
+		"game join csgo wow dota2 overwatch lol smash pubg",
 	}
 }
 
@@ -46,6 +50,10 @@ func (ip *IPlay) Ack(env *aoebot.Environment) string {
 	return "🆗"
 }
 
+func (ip *IPlay) Complete(env *aoebot.Environment, option string, partial string) []string {
+	return aoebot.CompleteLast(partial, getAllGames(env.Bot))
+}
+
 func (ip *IPlay) Run(env *aoebot.Environment, args []string) error {
 	if len(args) == 0 {
 		return errors.New("no games 😦")
@@ -55,8 +63,8 @@ func (ip *IPlay) Run(env *aoebot.Environment, args []string) error {
 	}
 	games, missing := aliasesToGames(env.Bot, args)
 	if len(missing) > 0 {
-		missingStr := "I haven't heard of " + strings.Join(missing, ", ") + ". Try using `addgame` for new games."
-		env.Bot.Write(env.TextChannel.ID, missingStr, false)
+		missingStr := "I haven't heard of " + strings.Join(missing, ", ") + ". Try using `game add` for new games."
+		env.Reply(missingStr)
 	}
 	if len(games) == 0 {
 		return errors.New("no games 😦")
@@ -95,7 +103,7 @@ func aliasesToGames(bot *aoebot.Bot, aliases []string) (games []string, missing
 // ugh TODO cleanup
 // get roleIDs that correspond to a game name in a given guild
 // optionally create roles for games that do not have one
-func getRolesByGame(bot *aoebot.Bot, prefs *guildPrefs, games []string, createIfMissing bool) (roleIDs []string) {
+func getRolesByGame(bot *aoebot.Bot, prefs *aoebot.GuildPrefs, games []string, createIfMissing bool) (roleIDs []string) {
 	createdAnyRoles := false
 	for _, game := range games {
 		game = strings.ToLower(game)
@@ -124,7 +132,7 @@ func getRolesByGame(bot *aoebot.Bot, prefs *guildPrefs, games []string, createIf
 
 // true when a guild has a role with the provided id
 func isRoleInGuild(bot *aoebot.Bot, guildID string, roleID string) bool {
-	_, err := bot.Session.State.Role(guildID, roleID)
+	_, err := bot.State.Role(guildID, roleID)
 	return err == nil
 }
 
@@ -156,8 +164,12 @@ func (idp *IDontPlay) Name() string {
 	return strings.Fields(idp.Usage())[0]
 }
 
+func (idp *IDontPlay) Aliases() []string {
+	return []string{"idontplay"}
+}
+
 func (idp *IDontPlay) Usage() string {
-	return "idontplay [name]..."
+	return "leave [name]..."
 }
 
 func (idp *IDontPlay) Short() string {
@@ -165,14 +177,14 @@ func (idp *IDontPlay) Short() string {
 }
 
 func (idp *IDontPlay) Long() string {
-	return `Remove a role assigned with addgame.
+	return `Remove a role assigned with game add.
 You can remove multiple roles at the same time.  Separate game names with spaces.`
 }
 
 func (idp *IDontPlay) Examples() []string {
 	return []string{
-		"idontplay hots",
-		"idontplay csgo pubg dota2",
+		"game leave hots",
+		"game leave csgo pubg dota2",
 	}
 }
 
@@ -180,6 +192,10 @@ func (idb *IDontPlay) Ack(env *aoebot.Environment) string {
 	return "🆗"
 }
 
+func (idp *IDontPlay) Complete(env *aoebot.Environment, option string, partial string) []string {
+	return aoebot.CompleteLast(partial, getAllGames(env.Bot))
+}
+
 func (idp *IDontPlay) Run(env *aoebot.Environment, args []string) error {
 	if len(args) == 0 {
 		return errors.New("no games 😦")
@@ -190,7 +206,7 @@ func (idp *IDontPlay) Run(env *aoebot.Environment, args []string) error {
 	games, missing := aliasesToGames(env.Bot, args)
 	if len(missing) > 0 {
 		missingStr := "I haven't heard of " + strings.Join(missing, ",")
-		env.Bot.Write(env.TextChannel.ID, missingStr, false)
+		env.Reply(missingStr)
 	}
 	if len(games) == 0 {
 		return nil
@@ -0,0 +1,308 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jeffreymkabot/aoebot"
+)
+
+var regexFlag = aoebot.Flag{Name: "regex", Type: aoebot.ArgBool, Usage: "parse phrase as a regular expression"}
+
+var reactionFlag = aoebot.Flag{Name: "reaction", Type: aoebot.ArgEmoji, Usage: "respond when someone reacts with emoji to a message"}
+
+var matchFlag = aoebot.Flag{Name: "match", Value: "mode", Usage: "match phrase mode", Choices: []string{
+	string(aoebot.MatchContains),
+	string(aoebot.MatchPrefix),
+	string(aoebot.MatchWord),
+}}
+
+// addMemeFlags are the flags shared by the commands that add memes
+var addMemeFlags = []aoebot.Flag{
+	reactionFlag,
+	{Name: "cooldown", Type: aoebot.ArgDuration, Usage: "wait duration before responding again"},
+	{Name: "per", Value: "scope", Usage: "track cooldown per scope", Choices: []string{
+		string(aoebot.CooldownUser),
+		string(aoebot.CooldownChannel),
+	}},
+	{Name: "chance", Type: aoebot.ArgInt, Value: "percent", Usage: "respond percent of the time"},
+	{Name: "or", Type: aoebot.ArgBool, Usage: "add an alternative to an existing meme"},
+	{Name: "weight", Type: aoebot.ArgInt, Default: "1", Usage: "make an alternative n times as likely"},
+	{Name: "then", Type: aoebot.ArgBool, Usage: "add a step to an existing meme"},
+	{Name: "delay", Type: aoebot.ArgDuration, Usage: "wait duration before the step"},
+}
+
+// delMemeFlags are the flags shared by the commands that delete memes
+var delMemeFlags = []aoebot.Flag{
+	reactionFlag,
+	{Name: "or", Type: aoebot.ArgBool, Usage: "remove an alternative from an existing meme"},
+	{Name: "then", Type: aoebot.ArgBool, Usage: "remove a step from an existing meme"},
+}
+
+var memeExclusive = [][]string{
+	{"regex", "match"},
+	{"or", "then"},
+}
+
+// memeArgs are the positional arguments of commands that add or delete memes, [subject] on [phrase]
+func memeArgs(subject string, subjectType aoebot.ArgType) []aoebot.Arg {
+	return []aoebot.Arg{
+		{Name: subject, Type: subjectType},
+		{Name: "on", Type: aoebot.ArgLiteral},
+		{Name: "phrase", Type: aoebot.ArgPhrase},
+	}
+}
+
+// checkAddMeme is an error if a meme can not be added to the environment's guild
+func checkAddMeme(env *aoebot.Environment, args *aoebot.Args) error {
+	if err := checkChance(args.Int("chance")); err != nil {
+		return err
+	}
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	limit := env.Bot.Config.MaxManagedConditions
+	// adding to an existing meme replaces it, so it only needs to fit in the limit
+	if args.Bool("or") || args.Bool("then") {
+		limit++
+	}
+	if len(env.Bot.Driver.ConditionsGuild(env.Guild.ID)) >= limit {
+		return errors.New("I'm not allowed make any more memes in this guild")
+	}
+	return nil
+}
+
+// setPhrase sets either the regex phrase or the match mode and phrase of a condition
+// With the -reaction flag the condition is met by reacting with an emoji to a message that matches the phrase,
+// and an empty phrase matches any message
+func setPhrase(cond *aoebot.Condition, args *aoebot.Args) error {
+	phrase := args.String("phrase")
+	if args.IsSet("reaction") {
+		cond.EnvironmentType = aoebot.Reaction
+		cond.Emoji = args.String("reaction")
+		if phrase == "" {
+			return nil
+		}
+	}
+	if phrase == "" {
+		return errors.New("Couldn't parse phrase")
+	}
+	if args.Bool("regex") {
+		// lowercasing a regex would change escapes like \W into their opposites, so ignore case instead
+		if !strings.HasPrefix(phrase, "(?i)") {
+			phrase = "(?i)" + phrase
+		}
+		regexPhrase, err := regexp.Compile(phrase)
+		if err != nil {
+			return err
+		}
+		cond.RegexPhrase = regexPhrase.String()
+		return nil
+	}
+	cond.Phrase = strings.ToLower(phrase)
+	cond.MatchMode = aoebot.MatchMode(args.String("match"))
+	return nil
+}
+
+// addMeme adds a condition as a new meme, or to an existing meme with the -or and -then flags
+func addMeme(env *aoebot.Environment, cond *aoebot.Condition, args *aoebot.Args) error {
+	cond.Cooldown = args.Duration("cooldown")
+	cond.CooldownScope = aoebot.CooldownScope(args.String("per"))
+	cond.Chance = args.Int("chance")
+	if args.Bool("or") {
+		return addAlternative(env, cond, args.Int("weight"))
+	}
+	if args.Bool("then") {
+		return addStep(env, cond, args.Duration("delay"))
+	}
+	return env.Bot.Driver.ConditionAdd(cond, env.Author.String())
+}
+
+// delMeme removes a meme, or part of an existing meme with the -or and -then flags
+func delMeme(env *aoebot.Environment, cond *aoebot.Condition, args *aoebot.Args) error {
+	if args.Bool("or") {
+		return delAlternative(env, cond)
+	}
+	if args.Bool("then") {
+		return delStep(env, cond)
+	}
+	if err := env.Bot.Driver.ConditionDisable(cond); err != nil {
+		return err
+	}
+	if legacy := legacyRegex(cond.RegexPhrase); legacy != cond.RegexPhrase {
+		old := *cond
+		old.RegexPhrase = legacy
+		return env.Bot.Driver.ConditionDisable(&old)
+	}
+	return nil
+}
+
+// legacyRegex is how a regex phrase was stored before setPhrase made it ignore case: lowercased without (?i)
+func legacyRegex(regexPhrase string) string {
+	return strings.ToLower(strings.TrimPrefix(regexPhrase, "(?i)"))
+}
+
+// sameRegex is true if a stored regex phrase was set from the same regex as regexPhrase
+func sameRegex(stored string, regexPhrase string) bool {
+	return stored == regexPhrase || stored == legacyRegex(regexPhrase)
+}
+
+// checkChance is an error unless chance is a percent or 0 for always
+func checkChance(chance int) error {
+	if chance < 0 || chance > 100 {
+		return fmt.Errorf("Chance should be a percent between 1 and 100, not %v", chance)
+	}
+	return nil
+}
+
+// findMeme finds an existing meme in a guild that has the same trigger as cond
+// prefer a meme that already combines several actions
+func findMeme(env *aoebot.Environment, cond *aoebot.Condition) (*aoebot.Condition, error) {
+	var found *aoebot.Condition
+	for _, c := range env.Bot.Driver.ConditionsGuild(env.Guild.ID) {
+		if c.EnvironmentType != cond.EnvironmentType ||
+			c.Emoji != cond.Emoji ||
+			c.Phrase != cond.Phrase ||
+			c.MatchMode != cond.MatchMode ||
+			!sameRegex(c.RegexPhrase, cond.RegexPhrase) {
+			continue
+		}
+		c := c
+		if found == nil || isComposite(c.Action.Action) {
+			found = &c
+		}
+	}
+	if found == nil {
+		return nil, errors.New("There isn't a meme on that phrase yet")
+	}
+	return found, nil
+}
+
+func isComposite(a aoebot.Action) bool {
+	switch a.(type) {
+	case *aoebot.RandomAction, *aoebot.SequenceAction:
+		return true
+	}
+	return false
+}
+
+// replaceMeme swaps the action of an existing meme
+func replaceMeme(env *aoebot.Environment, existing *aoebot.Condition, action aoebot.Action) error {
+	if err := env.Bot.Driver.ConditionDisable(existing); err != nil {
+		return err
+	}
+	replacement := *existing
+	replacement.Name = ""
+	replacement.IsEnabled = false
+	replacement.CreatedBy = ""
+	replacement.Action = aoebot.NewActionEnvelope(action)
+	return env.Bot.Driver.ConditionAdd(&replacement, env.Author.String())
+}
+
+// addAlternative makes the action of cond an alternative to the action of the meme with the same trigger
+// one of the alternatives is picked at random each time the meme is triggered
+func addAlternative(env *aoebot.Environment, cond *aoebot.Condition, weight int) error {
+	existing, err := findMeme(env, cond)
+	if err != nil {
+		return err
+	}
+	choice := aoebot.WeightedAction{Weight: weight, Action: cond.Action}
+	ra, ok := existing.Action.Action.(*aoebot.RandomAction)
+	if ok {
+		// existing has to stay as it is stored so replaceMeme can disable it
+		choices := append([]aoebot.WeightedAction{}, ra.Choices...)
+		ra = &aoebot.RandomAction{Choices: append(choices, choice)}
+	} else {
+		ra = &aoebot.RandomAction{
+			Choices: []aoebot.WeightedAction{
+				{Weight: 1, Action: existing.Action},
+				choice,
+			},
+		}
+	}
+	return replaceMeme(env, existing, ra)
+}
+
+// delAlternative removes the action of cond from the alternatives of the meme with the same trigger
+func delAlternative(env *aoebot.Environment, cond *aoebot.Condition) error {
+	existing, err := findMeme(env, cond)
+	if err != nil {
+		return err
+	}
+	ra, ok := existing.Action.Action.(*aoebot.RandomAction)
+	if !ok {
+		return errors.New("That meme doesn't have any alternatives")
+	}
+	choices := []aoebot.WeightedAction{}
+	for _, choice := range ra.Choices {
+		if !reflect.DeepEqual(choice.Action, cond.Action) {
+			choices = append(choices, choice)
+		}
+	}
+	if len(choices) == len(ra.Choices) {
+		return errors.New("That isn't one of the alternatives")
+	}
+	if len(choices) == 1 {
+		return replaceMeme(env, existing, choices[0].Action.Action)
+	}
+	if len(choices) == 0 {
+		return env.Bot.Driver.ConditionDisable(existing)
+	}
+	return replaceMeme(env, existing, &aoebot.RandomAction{Choices: choices})
+}
+
+// addStep makes the action of cond a step that follows the action of the meme with the same trigger
+// the step waits for delay after the previous step
+func addStep(env *aoebot.Environment, cond *aoebot.Condition, delay time.Duration) error {
+	existing, err := findMeme(env, cond)
+	if err != nil {
+		return err
+	}
+	step := aoebot.SequenceStep{Delay: delay, Action: cond.Action}
+	sa, ok := existing.Action.Action.(*aoebot.SequenceAction)
+	if ok {
+		// existing has to stay as it is stored so replaceMeme can disable it
+		steps := append([]aoebot.SequenceStep{}, sa.Steps...)
+		sa = &aoebot.SequenceAction{Steps: append(steps, step)}
+	} else {
+		sa = &aoebot.SequenceAction{
+			Steps: []aoebot.SequenceStep{
+				{Action: existing.Action},
+				step,
+			},
+		}
+	}
+	return replaceMeme(env, existing, sa)
+}
+
+// delStep removes the action of cond from the steps of the meme with the same trigger
+func delStep(env *aoebot.Environment, cond *aoebot.Condition) error {
+	existing, err := findMeme(env, cond)
+	if err != nil {
+		return err
+	}
+	sa, ok := existing.Action.Action.(*aoebot.SequenceAction)
+	if !ok {
+		return errors.New("That meme doesn't have any steps")
+	}
+	steps := []aoebot.SequenceStep{}
+	for _, step := range sa.Steps {
+		if !reflect.DeepEqual(step.Action, cond.Action) {
+			steps = append(steps, step)
+		}
+	}
+	if len(steps) == len(sa.Steps) {
+		return errors.New("That isn't one of the steps")
+	}
+	if len(steps) == 1 {
+		return replaceMeme(env, existing, steps[0].Action.Action)
+	}
+	if len(steps) == 0 {
+		return env.Bot.Driver.ConditionDisable(existing)
+	}
+	return replaceMeme(env, existing, &aoebot.SequenceAction{Steps: steps})
+}
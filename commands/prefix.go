@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jeffreymkabot/aoebot"
+)
+
+const maxPrefixLength = 16
+
+type Prefix struct {
+	aoebot.BaseCommand
+}
+
+func (p *Prefix) Name() string {
+	return "prefix"
+}
+
+func (p *Prefix) Schema() aoebot.Schema {
+	return aoebot.Schema{
+		Flags: []aoebot.Flag{
+			{Name: "reset", Type: aoebot.ArgBool, Usage: "restore the default prefix"},
+		},
+		Args: []aoebot.Arg{
+			{Name: "prefix", Optional: true},
+		},
+	}
+}
+
+func (p *Prefix) Short() string {
+	return `Change how my commands start in this guild`
+}
+
+func (p *Prefix) Long() string {
+	return `Change the prefix that my commands start with in this guild to [prefix].
+Without a [prefix] I will tell you the prefix this guild uses.
+Use the [-reset] flag to go back to the default prefix.
+You can always start a command by mentioning me instead.
+Only members who can manage this guild can change the prefix.`
+}
+
+func (p *Prefix) Examples() []string {
+	return []string{
+		`prefix`,
+		`prefix !aoe`,
+		`prefix -reset`,
+	}
+}
+
+func (p *Prefix) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	if !args.IsSet("prefix") && !args.Bool("reset") {
+		return env.Reply(fmt.Sprintf("My commands start with `%s` in this guild", env.Prefix()))
+	}
+
+	prefix := args.String("prefix")
+	if args.Bool("reset") {
+		prefix = ""
+	} else if err := checkPrefix(prefix); err != nil {
+		return err
+	}
+
+	prefs, err := getGuildPrefs(env.Bot, env.Guild.ID)
+	if err == aoebot.ErrNotFound {
+		prefs = &aoebot.GuildPrefs{GuildID: env.Guild.ID}
+	} else if err != nil {
+		return errors.New("couldn't lookup guild data 😦")
+	}
+	prefs.Prefix = prefix
+	return setGuildPrefs(env.Bot, prefs)
+}
+
+func (p *Prefix) IsAdminOnly() bool {
+	return true
+}
+
+func (p *Prefix) Ack(env *aoebot.Environment) string {
+	return "✅"
+}
+
+func checkPrefix(prefix string) error {
+	if prefix == "" || strings.ContainsAny(prefix, " \t\n") {
+		return errors.New("Prefix can't be empty or have spaces")
+	}
+	if len(prefix) > maxPrefixLength {
+		return fmt.Errorf("Prefix can't be longer than %v characters", maxPrefixLength)
+	}
+	return nil
+}
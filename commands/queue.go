@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jeffreymkabot/aoebot"
+)
+
+type Queue struct {
+	aoebot.BaseCommand
+}
+
+func (q *Queue) Name() string {
+	return strings.Fields(q.Usage())[0]
+}
+
+func (q *Queue) Aliases() []string {
+	return []string{"q"}
+}
+
+func (q *Queue) Usage() string {
+	return "queue"
+}
+
+func (q *Queue) Short() string {
+	return "List the clips waiting to be said"
+}
+
+func (q *Queue) Long() string {
+	return `List the clip I am saying in this guild and the clips waiting after it, in the order I will say them.`
+}
+
+func (q *Queue) Run(env *aoebot.Environment, args []string) error {
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	items := env.Bot.Queue(env.Guild.ID)
+	if len(items) == 0 {
+		return env.Reply("Nothing is queued 🔇")
+	}
+	buf := &bytes.Buffer{}
+	waiting := 0
+	for _, item := range items {
+		channel := item.ChannelID
+		if ch, err := env.Bot.State.Channel(item.ChannelID); err == nil {
+			channel = ch.Name
+		}
+		if item.Playing {
+			fmt.Fprintf(buf, "🔊 `%s` in %s\n", item.Title, channel)
+		} else {
+			waiting++
+			fmt.Fprintf(buf, "%d. `%s` in %s\n", waiting, item.Title, channel)
+		}
+	}
+	return env.Reply(buf.String())
+}
+
+type Skip struct {
+	aoebot.BaseCommand
+}
+
+func (s *Skip) Name() string {
+	return strings.Fields(s.Usage())[0]
+}
+
+func (s *Skip) Usage() string {
+	return "skip"
+}
+
+func (s *Skip) Short() string {
+	return "Stop saying the current clip"
+}
+
+func (s *Skip) Long() string {
+	return `Stop saying the clip I am saying in this guild and move on to the next one in the queue.`
+}
+
+func (s *Skip) Run(env *aoebot.Environment, args []string) error {
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	return env.Bot.Skip(env.Guild.ID)
+}
+
+func (s *Skip) Ack(env *aoebot.Environment) string {
+	return "⏭"
+}
+
+type Clear struct {
+	aoebot.BaseCommand
+}
+
+func (c *Clear) Name() string {
+	return strings.Fields(c.Usage())[0]
+}
+
+func (c *Clear) Aliases() []string {
+	return []string{"clearqueue"}
+}
+
+func (c *Clear) Usage() string {
+	return "clear"
+}
+
+func (c *Clear) Short() string {
+	return "Forget the clips waiting to be said"
+}
+
+func (c *Clear) Long() string {
+	return `Remove every clip waiting in the queue of this guild.  I will finish saying the current clip.`
+}
+
+func (c *Clear) Run(env *aoebot.Environment, args []string) error {
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	return env.Bot.ClearQueue(env.Guild.ID)
+}
+
+func (c *Clear) Ack(env *aoebot.Environment) string {
+	return "🗑"
+}
+
+type Stop struct {
+	aoebot.BaseCommand
+}
+
+func (s *Stop) Name() string {
+	return strings.Fields(s.Usage())[0]
+}
+
+func (s *Stop) Aliases() []string {
+	return []string{"shutup"}
+}
+
+func (s *Stop) Usage() string {
+	return "stop"
+}
+
+func (s *Stop) Short() string {
+	return "Stop saying anything"
+}
+
+func (s *Stop) Long() string {
+	return `Stop saying the current clip and remove every clip waiting in the queue of this guild.`
+}
+
+func (s *Stop) Run(env *aoebot.Environment, args []string) error {
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	return env.Bot.StopVoice(env.Guild.ID)
+}
+
+func (s *Stop) Ack(env *aoebot.Environment) string {
+	return "⏹"
+}
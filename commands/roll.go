@@ -57,8 +57,13 @@ func (r *Roll) Run(env *aoebot.Environment, args []string) error {
 	}
 
 	result := rand.Intn(n) + 1
+	// build suspense without holding up the worker if the bot stops
 	env.Bot.Session.ChannelTyping(env.TextChannel.ID)
-	time.Sleep(1 * time.Second)
+	select {
+	case <-time.After(1 * time.Second):
+	case <-env.Context.Done():
+		return env.Context.Err()
+	}
 	message := fmt.Sprintf("%s rolled %d on a d%d.", env.Author.Username, result, n)
-	return env.Bot.Write(env.TextChannel.ID, message, false)
+	return env.Reply(message)
 }
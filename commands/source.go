@@ -25,5 +25,5 @@ func (s *Source) Long() string {
 }
 
 func (s *Source) Run(env *aoebot.Environment, args []string) error {
-	return env.Bot.Write(env.TextChannel.ID, `https://github.com/jeffreymkabot/aoebot/tree/develop`, false)
+	return env.Reply(`https://github.com/jeffreymkabot/aoebot/tree/develop`)
 }
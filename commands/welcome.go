@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/jeffreymkabot/aoebot"
+)
+
+type Welcome struct {
+	aoebot.BaseCommand
+}
+
+func (w *Welcome) Name() string {
+	return "welcome"
+}
+
+func (w *Welcome) Schema() aoebot.Schema {
+	return greetSchema
+}
+
+func (w *Welcome) Short() string {
+	return `Greet members when they join`
+}
+
+func (w *Welcome) Long() string {
+	return `Write [message] when a member joins this guild.
+The message is written to the guild's spam channel if one is set up, otherwise to the guild's system channel.
+[message] can be a template, e.g. {{.Author.Mention}} or {{.Author.Username}} for the member who joined.
+Template syntax described here: https://golang.org/pkg/text/template.
+Using welcome again replaces the message.  Use the [-off] flag to stop welcoming members.`
+}
+
+func (w *Welcome) Examples() []string {
+	return []string{
+		`welcome "welcome to the guild {{.Author.Mention}} :wave:"`,
+		`welcome -off`,
+	}
+}
+
+func (w *Welcome) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	return greet(env, aoebot.MemberJoin, args)
+}
+
+func (w *Welcome) Ack(env *aoebot.Environment) string {
+	return "✅"
+}
+
+type Farewell struct {
+	aoebot.BaseCommand
+}
+
+func (f *Farewell) Name() string {
+	return "farewell"
+}
+
+func (f *Farewell) Schema() aoebot.Schema {
+	return greetSchema
+}
+
+func (f *Farewell) Short() string {
+	return `Say goodbye to members when they leave`
+}
+
+func (f *Farewell) Long() string {
+	return `Write [message] when a member leaves this guild.
+The message is written to the guild's spam channel if one is set up, otherwise to the guild's system channel.
+[message] can be a template, e.g. {{.Author.Username}} for the member who left.
+Template syntax described here: https://golang.org/pkg/text/template.
+Using farewell again replaces the message.  Use the [-off] flag to stop saying goodbye to members.`
+}
+
+func (f *Farewell) Examples() []string {
+	return []string{
+		`farewell "{{.Author.Username}} has left the building"`,
+		`farewell -off`,
+	}
+}
+
+func (f *Farewell) RunArgs(env *aoebot.Environment, args *aoebot.Args) error {
+	return greet(env, aoebot.MemberLeave, args)
+}
+
+func (f *Farewell) Ack(env *aoebot.Environment) string {
+	return "✅"
+}
+
+var greetSchema = aoebot.Schema{
+	Flags: []aoebot.Flag{
+		{Name: "off", Type: aoebot.ArgBool, Usage: "stop writing a message"},
+	},
+	Args: []aoebot.Arg{
+		{Name: "message", Type: aoebot.ArgPhrase, Optional: true},
+	},
+}
+
+// greet replaces the message written when a member joins or leaves a guild
+func greet(env *aoebot.Environment, envType aoebot.EnvironmentType, args *aoebot.Args) error {
+	if args.Bool("off") == args.IsSet("message") {
+		return errors.New("Use either the [-off] flag or a message")
+	}
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+
+	var cond *aoebot.Condition
+	if !args.Bool("off") {
+		action := &aoebot.WriteAction{
+			Content: args.String("message"),
+		}
+		if _, err := action.ParseTemplate(); err != nil {
+			return err
+		}
+		cond = &aoebot.Condition{
+			EnvironmentType: envType,
+			GuildID:         env.Guild.ID,
+			Action:          aoebot.NewActionEnvelope(action),
+		}
+	}
+
+	for _, c := range env.Bot.Driver.ConditionsGuild(env.Guild.ID) {
+		if c.EnvironmentType != envType {
+			continue
+		}
+		if err := env.Bot.Driver.ConditionDisable(&c); err != nil {
+			return err
+		}
+	}
+	if cond == nil {
+		return nil
+	}
+	return env.Bot.Driver.ConditionAdd(cond, env.Author.String())
+}
@@ -0,0 +1,108 @@
+package aoebot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CooldownScope is what a Condition's cooldown is tracked against
+// The zero value tracks a single cooldown for the condition no matter who or where triggered it
+type CooldownScope string
+
+const (
+	CooldownCondition CooldownScope = ""
+	CooldownUser      CooldownScope = "user"
+	CooldownChannel   CooldownScope = "channel"
+)
+
+// String implements flag.Value
+func (cs CooldownScope) String() string {
+	return string(cs)
+}
+
+// Set implements flag.Value
+func (cs *CooldownScope) Set(s string) error {
+	switch scope := CooldownScope(strings.ToLower(s)); scope {
+	case CooldownCondition, CooldownUser, CooldownChannel:
+		*cs = scope
+		return nil
+	}
+	return fmt.Errorf("Unsupported cooldown scope %v, try user or channel", s)
+}
+
+// how often to forget cooldowns that have expired
+const cooldownSweepInterval = 10 * time.Minute
+
+// cooldowns tracks when conditions are allowed to be dispatched again
+type cooldowns struct {
+	mu        sync.Mutex
+	until     map[string]time.Time
+	lastSweep time.Time
+}
+
+func newCooldowns() *cooldowns {
+	return &cooldowns{
+		until:     make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// take is true when a condition is not cooling down in an environment
+// take starts the condition's cooldown when it is true
+func (cd *cooldowns) take(env *Environment, c Condition) bool {
+	if c.Cooldown <= 0 {
+		return true
+	}
+	key := cooldownKey(env, c)
+	now := time.Now()
+
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	if now.Sub(cd.lastSweep) > cooldownSweepInterval {
+		for k, until := range cd.until {
+			if now.After(until) {
+				delete(cd.until, k)
+			}
+		}
+		cd.lastSweep = now
+	}
+	if until, ok := cd.until[key]; ok && now.Before(until) {
+		return false
+	}
+	cd.until[key] = now.Add(c.Cooldown)
+	return true
+}
+
+// remaining is how much longer a condition is cooling down in an environment
+func (cd *cooldowns) remaining(env *Environment, c Condition) time.Duration {
+	if c.Cooldown <= 0 {
+		return 0
+	}
+	key := cooldownKey(env, c)
+
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	if left := time.Until(cd.until[key]); left > 0 {
+		return left
+	}
+	return 0
+}
+
+func cooldownKey(env *Environment, c Condition) string {
+	key := fmt.Sprintf("%v/%v/%v", c.EnvironmentType, c.GuildID, c.GeneratedName())
+	switch c.CooldownScope {
+	case CooldownUser:
+		if env.Author != nil {
+			key += "/user/" + env.Author.ID
+		}
+	case CooldownChannel:
+		if env.TextChannel != nil {
+			key += "/channel/" + env.TextChannel.ID
+		} else if env.VoiceChannel != nil {
+			key += "/channel/" + env.VoiceChannel.ID
+		}
+	}
+	return key
+}
@@ -0,0 +1,85 @@
+package aoebot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestCooldowns(t *testing.T) {
+	bob := &discordgo.User{ID: "u"}
+	eve := &discordgo.User{ID: "v"}
+	env := func(author *discordgo.User, channelID string) *Environment {
+		return &Environment{
+			Type:        Message,
+			Guild:       &discordgo.Guild{ID: "g"},
+			Author:      author,
+			TextChannel: &discordgo.Channel{ID: channelID},
+		}
+	}
+	meme := func(scope CooldownScope, regex string) Condition {
+		return Condition{
+			EnvironmentType: Message,
+			GuildID:         "g",
+			RegexPhrase:     regex,
+			Action:          NewActionEnvelope(&WriteAction{Content: "pong"}),
+			Cooldown:        time.Minute,
+			CooldownScope:   scope,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		first *Environment
+		c     Condition
+		then  *Environment
+		other Condition
+		take  bool
+	}{
+		{"again", env(bob, "c"), meme(CooldownCondition, "^ping$"), env(bob, "c"), meme(CooldownCondition, "^ping$"), false},
+		{"someone else", env(bob, "c"), meme(CooldownCondition, "^ping$"), env(eve, "c"), meme(CooldownCondition, "^ping$"), false},
+		{"another meme", env(bob, "c"), meme(CooldownCondition, "^ping$"), env(bob, "c"), meme(CooldownCondition, "^pong$"), true},
+		{"per user", env(bob, "c"), meme(CooldownUser, "^ping$"), env(eve, "c"), meme(CooldownUser, "^ping$"), true},
+		{"per user again", env(bob, "c"), meme(CooldownUser, "^ping$"), env(bob, "d"), meme(CooldownUser, "^ping$"), false},
+		{"per channel", env(bob, "c"), meme(CooldownChannel, "^ping$"), env(bob, "d"), meme(CooldownChannel, "^ping$"), true},
+		{"per channel again", env(bob, "c"), meme(CooldownChannel, "^ping$"), env(eve, "c"), meme(CooldownChannel, "^ping$"), false},
+	}
+	for _, tt := range tests {
+		cd := newCooldowns()
+		if !cd.take(tt.first, tt.c) {
+			t.Errorf("%v: first take should be allowed", tt.name)
+		}
+		if got := cd.take(tt.then, tt.other); got != tt.take {
+			t.Errorf("%v: take = %v, want %v", tt.name, got, tt.take)
+		}
+	}
+}
+
+func TestCooldownRemaining(t *testing.T) {
+	cd := newCooldowns()
+	env := &Environment{Type: Message, Guild: &discordgo.Guild{ID: "g"}}
+	c := Condition{EnvironmentType: Message, GuildID: "g", Phrase: "ping", Cooldown: time.Minute}
+	if left := cd.remaining(env, c); left != 0 {
+		t.Errorf("remaining before take = %v", left)
+	}
+	cd.take(env, c)
+	if left := cd.remaining(env, c); left <= 0 || left > time.Minute {
+		t.Errorf("remaining after take = %v", left)
+	}
+
+	c.Cooldown = 0
+	if !cd.take(env, c) || !cd.take(env, c) {
+		t.Error("a condition without a cooldown should always be allowed")
+	}
+}
+
+func TestCooldownScopeSet(t *testing.T) {
+	var scope CooldownScope
+	if err := scope.Set("User"); err != nil || scope != CooldownUser {
+		t.Errorf("Set(User) = %v, %v", scope, err)
+	}
+	if err := scope.Set("guild"); err == nil {
+		t.Error("Set(guild) should fail")
+	}
+}
@@ -1,81 +1,115 @@
 package aoebot
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
-// Driver is a wrapper around a MongoDB session
-// Actions are discovered as subdocments of entries in the "conditions" collection
+// Driver is a wrapper around a Store
+// Actions are discovered as subdocments of conditions in the Store
 // Conditions specify properties of Environments that they correspond to
+// Driver keeps an index of enabled conditions that is rebuilt after it adds or disables a condition
+// Driver also keeps the command prefix of each guild, which is looked up for every message
 type Driver struct {
-	*mgo.Session
+	Store
+	mu       sync.RWMutex
+	index    *conditionIndex
+	prefixes map[string]string
 }
 
-// newDriver starts a new MongoDB session
+// newDriver opens a new Store
 // Clients SHOULD call Driver.Close() to stop any Drivers they start
-func newDriver(dbURL string) (d *Driver, err error) {
-	session, err := mgo.Dial(dbURL)
+func newDriver(dial StoreDialer) (d *Driver, err error) {
+	store, err := dial()
+	if err != nil {
+		return
+	}
 	d = &Driver{
-		session,
+		Store:    store,
+		prefixes: make(map[string]string),
 	}
 	return
 }
 
-// actions are discovered as subdocments of entries in the "conditions" collection
-// Conditions specify properties of Environments that they correspond to
-func (d *Driver) actions(env *Environment) []Action {
-	coll := d.DB("aoebot").C("conditions")
-	query := queryEnvironment(env)
-	log.Printf("Using query %s", query)
+// match is a condition that is satisfied by an environment
+// Captures are the groups captured by the condition's regex phrase
+type match struct {
+	Condition
+	Captures []string
+}
 
-	conditions := []Condition{}
-	err := coll.Find(query).All(&conditions)
-	if err != nil {
-		log.Printf("Error in query %v", err)
+// matches are discovered in the Store
+// Conditions specify properties of Environments that they correspond to
+func (d *Driver) matches(env *Environment) []match {
+	matches := []match{}
+	// captures are echoed back, so match against the content as it was written
+	content := ""
+	if env.TextMessage != nil {
+		content = env.TextMessage.Content
 	}
-
-	actions := []Action{}
-	for _, cond := range conditions {
-		if cond.RegexPhrase != "" && env.TextMessage != nil {
-			re, err := regexp.Compile(cond.RegexPhrase)
-			if err == nil && re.MatchString(strings.ToLower(env.TextMessage.Content)) {
-				actions = append(actions, cond.Action.Action)
+	idx := d.conditionIndex()
+	if idx == nil {
+		// fall back to asking the store directly
+		for _, cond := range d.ConditionsEnvironment(env) {
+			if cond.RegexPhrase != "" && env.TextMessage != nil {
+				re, err := regexp.Compile(ignoreCase(cond.RegexPhrase))
+				if err != nil {
+					continue
+				}
+				if captures := re.FindStringSubmatch(content); captures != nil {
+					matches = append(matches, match{cond, captures})
+				}
+			} else {
+				matches = append(matches, match{Condition: cond})
 			}
-		} else {
-			actions = append(actions, cond.Action.Action)
 		}
+		return matches
+	}
+	for _, ic := range idx.lookup(env) {
+		m := match{Condition: ic.Condition}
+		if ic.regex != nil {
+			m.Captures = ic.regex.FindStringSubmatch(content)
+		}
+		matches = append(matches, m)
 	}
-	return actions
+	return matches
 }
 
-// ConditionsGuild returns all the custom conditions created through the discord message interface
-// that are exclusive to a particular guild.
-func (d *Driver) ConditionsGuild(guildID string) []Condition {
-	coll := d.DB("aoebot").C("conditions")
-	// conditions created through discord message interface always have createdby
-	query := bson.M{
-		"createdby": bson.M{
-			"$exists": true,
-		},
-		"guild":   guildID,
-		"enabled": true,
+// conditionIndex returns the index of enabled conditions, building it if it has been invalidated
+// conditionIndex returns nil if the index cannot be built
+func (d *Driver) conditionIndex() *conditionIndex {
+	d.mu.RLock()
+	idx := d.index
+	d.mu.RUnlock()
+	if idx != nil {
+		return idx
 	}
 
-	conditions := []Condition{}
-	err := coll.Find(query).All(&conditions)
-	if err != nil {
-		log.Printf("Error in query guild custom conditions %v", err)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.index == nil {
+		conditions, err := d.ConditionsEnabled()
+		if err != nil {
+			log.Printf("Error in index conditions %v", err)
+			return nil
+		}
+		d.index = newConditionIndex(conditions)
 	}
-	log.Printf("Found %v custom conditions for guild", len(conditions))
-	return conditions
+	return d.index
+}
+
+// invalidate discards the index of enabled conditions so it is rebuilt on the next lookup
+func (d *Driver) invalidate() {
+	d.mu.Lock()
+	d.index = nil
+	d.mu.Unlock()
 }
 
 // ConditionAdd inserts a new custom condition for a guild.
@@ -85,159 +119,116 @@ func (d *Driver) ConditionAdd(c *Condition, creator string) error {
 	if creator == "" {
 		return errors.New("Creator name is too short")
 	}
-
-	coll := d.DB("aoebot").C("conditions")
-	info, err := coll.Upsert(c, bson.M{
-		"$set": bson.M{
-			"name":      c.GeneratedName(),
-			"createdby": creator,
-			"enabled":   true,
-		},
-	})
-	if err == nil {
-		log.Printf("added Condition %#v", info)
-	}
-	return err
+	defer d.invalidate()
+	return d.Store.ConditionAdd(c, creator)
 }
 
 // ConditionDisable disables a condition and any of its duplicates.
 func (d *Driver) ConditionDisable(c *Condition) error {
-	coll := d.DB("aoebot").C("conditions")
-	info, err := coll.UpdateAll(c, bson.M{
-		"$set": bson.M{
-			"enabled": false,
-		},
-	})
-	if err == nil {
-		log.Printf("disabled Condition %#v", info)
-	}
-	return err
+	defer d.invalidate()
+	return d.Store.ConditionDisable(c)
 }
 
-// Channels retrieves all managed channels registered for any guild.
-func (d *Driver) Channels() []channel {
-	channels := []channel{}
-	coll := d.DB("aoebot").C("channels")
-	err := coll.Find(nil).All(&channels)
-	if err != nil {
-		log.Printf("Error in query managed channels %v", err)
-	}
-	return channels
-}
-
-// ChannelsGuild retrieves all managed channels registered for a particular guild.
-func (d *Driver) ChannelsGuild(guildID string) []channel {
-	channels := []channel{}
-	coll := d.DB("aoebot").C("channels")
-	query := bson.M{
-		"channel.guildid": guildID,
-	}
-	err := coll.Find(query).All(&channels)
-	if err != nil {
-		log.Printf("Error in query guild managed channels %v", err)
-	}
-	return channels
-}
-
-// ChannelsGuild registers a new managed channel.
-// Registered managed channels are recovered when the bot restarts.
-func (d *Driver) ChannelAdd(ch channel) error {
-	coll := d.DB("aoebot").C("channels")
-	return coll.Insert(ch)
-}
-
-// ChannelDelete unregisters a managed channel.
-// Managed channels that are unregistered are lost when the bot restarts.
-func (d *Driver) ChannelDelete(channelID ...string) error {
-	coll := d.DB("aoebot").C("channels")
-	query := bson.M{
-		"channel.id": bson.M{
-			"$in": channelID,
-		},
+// GuildPrefsSet saves the preferences of a guild and updates its command prefix.
+func (d *Driver) GuildPrefsSet(prefs *GuildPrefs) error {
+	if err := d.Store.GuildPrefsSet(prefs); err != nil {
+		return err
 	}
-	return coll.Remove(query)
-}
-
-type query bson.M
-
-// make queries pleasant to read in log messages
-func (q query) String() string {
-	queryjson, _ := json.Marshal(q)
-	return string(queryjson)
+	d.mu.Lock()
+	d.prefixes[prefs.GuildID] = prefs.Prefix
+	d.mu.Unlock()
+	return nil
 }
 
-func queryEnvironment(env *Environment) query {
-	and := []bson.M{
-		bson.M{
-			"enabled": true,
-		},
-	}
-	if env.Guild != nil {
-		and = append(and, emptyOrEqual("guild", env.Guild.ID))
-	}
-	if env.Author != nil {
-		and = append(and, emptyOrEqual("user", env.Author.ID))
-	}
-	if env.TextChannel != nil {
-		and = append(and, emptyOrEqual("textChannel", env.TextChannel.ID))
-	}
-	if env.VoiceChannel != nil {
-		and = append(and, emptyOrEqual("textChannel", env.VoiceChannel.ID))
-	}
-	phrase := ""
-	if env.TextMessage != nil {
-		phrase = strings.ToLower(env.TextMessage.Content)
+// Prefix is the command prefix saved for a guild, or empty when the guild has not saved one.
+func (d *Driver) Prefix(guildID string) string {
+	d.mu.RLock()
+	prefix, ok := d.prefixes[guildID]
+	d.mu.RUnlock()
+	if ok {
+		return prefix
 	}
-	and = append(and, emptyOrEqual("phrase", phrase))
-	return query(bson.M{
-		"type": env.Type,
-		"$and": and,
-	})
-}
-
-// bson clause { "$in": [value, null] }
-func emptyOrEqual(field string, value interface{}) bson.M {
-	return bson.M{
-		field: bson.M{
-			"$in": []interface{}{
-				value,
-				nil,
-			},
-		},
+	if prefs, err := d.Store.GuildPrefs(guildID); err == nil {
+		prefix = prefs.Prefix
+	} else if err != ErrNotFound {
+		log.Printf("Error looking up prefix of guild %v: %v", guildID, err)
+		return ""
 	}
+	d.mu.Lock()
+	d.prefixes[guildID] = prefix
+	d.mu.Unlock()
+	return prefix
 }
 
 // Condition defines a set of requirements an environment should meet
 // for a particular action to be performed on that environment.
+// The action is performed Chance percent of the time, or always when Chance is 0.
 type Condition struct {
 	// metadata
-	Name      string `json:"name,omitempty" bson:"name,omitempty"`
-	IsEnabled bool   `json:"enabled,omitempty" bson:"enabled,omitempty"`
-	CreatedBy string `json:"createdby,omitempty" bson:"createdby,omitempty"`
+	Name      string   `json:"name,omitempty" bson:"name,omitempty"`
+	IsEnabled bool     `json:"enabled,omitempty" bson:"enabled,omitempty"`
+	CreatedBy string   `json:"createdby,omitempty" bson:"createdby,omitempty"`
+	Tags      []string `json:"tags,omitempty" bson:"tags,omitempty"`
 
 	// requirements
 	EnvironmentType EnvironmentType `json:"type" bson:"type"`
 	Phrase          string          `json:"phrase,omitempty" bson:"phrase,omitempty"`
+	MatchMode       MatchMode       `json:"match,omitempty" bson:"match,omitempty"`
 	RegexPhrase     string          `json:"regex,omitempty" bson:"regex,omitempty"`
 	GuildID         string          `json:"guild,omitempty" bson:"guild,omitempty"`
 	TextChannelID   string          `json:"textChannel,omitempty" bson:"textChannel,omitempty"`
 	VoiceChannelID  string          `json:"voiceChannel,omitempty" bson:"voiceChannel,omitempty"`
 	UserID          string          `json:"user,omitempty" bson:"user,omitempty"`
+	Emoji           string          `json:"emoji,omitempty" bson:"emoji,omitempty"`
 
 	// behavior
-	Action ActionEnvelope `json:"action" bson:"action"`
+	Action        ActionEnvelope `json:"action" bson:"action"`
+	Cooldown      time.Duration  `json:"cooldown,omitempty" bson:"cooldown,omitempty"`
+	CooldownScope CooldownScope  `json:"cooldownScope,omitempty" bson:"cooldownScope,omitempty"`
+	Chance        int            `json:"chance,omitempty" bson:"chance,omitempty"`
+}
+
+// ignoreCase makes a regex phrase case insensitive
+// regex phrases saved before they were stored with (?i) were lowercased and matched against lowercased messages
+func ignoreCase(regexPhrase string) string {
+	if strings.HasPrefix(regexPhrase, "(?i)") {
+		return regexPhrase
+	}
+	return "(?i)" + regexPhrase
+}
+
+// identity is a copy of a condition without the fields that can change without making it a different condition.
+// identity is used to find duplicates of a condition.
+func (c Condition) identity() Condition {
+	c.Cooldown = 0
+	c.CooldownScope = CooldownCondition
+	c.Chance = 0
+	return c
 }
 
 // GeneratedName standardizes the name of a condition based its requirements and behavior.
 // GeneratedName emits a string that can be used as the exact argument to a Del* command.
 func (c Condition) GeneratedName() string {
+	flags := ""
+	if c.MatchMode != MatchExact {
+		flags = fmt.Sprintf("-match %s ", c.MatchMode)
+	}
+	if c.Emoji != "" {
+		flags += fmt.Sprintf("-reaction %s ", c.Emoji)
+	}
+	phrase := fmt.Sprintf("\"`%s`\"", c.Phrase)
+	if c.RegexPhrase != "" {
+		flags += "-regex "
+		phrase = fmt.Sprintf("`%s`", c.RegexPhrase)
+	}
 	if c.Action.Type == react {
-		if c.RegexPhrase != "" {
-			return fmt.Sprintf("%s -regex `%s` on `%s`", c.Action.Type, c.Action.Action, c.RegexPhrase)
-		}
-		return fmt.Sprintf("%s `%s` on \"`%s`\"", c.Action.Type, c.Action.Action, c.Phrase)
+		return fmt.Sprintf("%s %s`%s` on %s", c.Action.Type, flags, c.Action.Action, phrase)
 	}
-	return fmt.Sprintf("%s \"`%s`\" on \"`%s`\"", c.Action.Type, c.Action.Action, c.Phrase)
+	return fmt.Sprintf("%s %s\"`%s`\" on %s", c.Action.Type, flags, c.Action.Action, phrase)
+}
+
+func (c Condition) String() string {
+	return c.GeneratedName()
 }
 
 // ActionEnvelope encapsulates an Action and its ActionType.
@@ -257,9 +248,11 @@ func NewActionEnvelope(a Action) ActionEnvelope {
 
 // ActionTypeMap is used to retrieve an empty concrete Action corresponding to an ActionType.
 var ActionTypeMap = map[ActionType]func() Action{
-	write: func() Action { return &WriteAction{} },
-	voice: func() Action { return &VoiceAction{} },
-	react: func() Action { return &ReactAction{} },
+	write:    func() Action { return &WriteAction{} },
+	voice:    func() Action { return &VoiceAction{} },
+	react:    func() Action { return &ReactAction{} },
+	random:   func() Action { return &RandomAction{} },
+	sequence: func() Action { return &SequenceAction{} },
 }
 
 // SetBSON implements the bson.Setter interface.
@@ -1,6 +1,8 @@
 package aoebot
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -12,9 +14,14 @@ import (
 type EnvironmentType int
 
 const (
-	Message EnvironmentType = iota
-	Voicestate
+	Message    EnvironmentType = iota
+	Voicestate                 // a user joins a voice channel
 	Adhoc
+	MemberJoin
+	MemberLeave
+	Reaction
+	VoiceLeave
+	VoiceMove
 )
 
 // Environment captures an environment that can elicit bot actions
@@ -26,41 +33,169 @@ type Environment struct {
 	TextChannel  *discordgo.Channel
 	TextMessage  *discordgo.Message
 	VoiceChannel *discordgo.Channel
-	Author       *discordgo.User
+	// PrevVoiceChannel is the voice channel a user was connected to before they left or moved
+	PrevVoiceChannel *discordgo.Channel
+	Author           *discordgo.User
+	// Emoji is the emoji of a reaction, in the same form as ReactAction.Emoji
+	Emoji string
+	// Captures are the groups captured by the regex phrase of the condition that is being performed
+	Captures []string
+	// Interaction is the slash command that created the environment
+	Interaction *Interaction
+	// Context is done when the bot stops, or when a command running in the environment times out
+	Context context.Context
+}
+
+// Prefix is the command prefix used in the environment's guild
+func (env *Environment) Prefix() string {
+	if env.Guild == nil {
+		return env.Bot.Config.Prefix
+	}
+	return env.Bot.Prefix(env.Guild.ID)
+}
+
+// done is closed when the environment's context is done
+// done falls back to the bot's context if the environment was created without one
+func (env *Environment) done() <-chan struct{} {
+	if env.Context == nil {
+		return env.Bot.context().Done()
+	}
+	return env.Context.Done()
+}
+
+// Reply writes a message in response to the command that created the environment
+// A slash command is answered with an interaction reply instead of a message in the channel
+func (env *Environment) Reply(content string) error {
+	if env.Interaction != nil {
+		return env.Interaction.reply(content)
+	}
+	return env.Bot.Write(env.TextChannel.ID, content, false)
+}
+
+// ReplyEmbed is like Reply for an embed
+func (env *Environment) ReplyEmbed(embed *discordgo.MessageEmbed) error {
+	if env.Interaction != nil {
+		return env.Interaction.reply("", embed)
+	}
+	_, err := env.Bot.Session.ChannelMessageSendEmbed(env.TextChannel.ID, embed)
+	return err
+}
+
+// VoiceStateChange seeds an environment where a user joins, leaves, or moves between voice channels
+// A user joined when PrevChannelID is empty and left when ChannelID is empty
+type VoiceStateChange struct {
+	*discordgo.VoiceState
+	PrevChannelID string
 }
 
 // NewEnvironment creates a new environment based on a seed event/trigger
 func NewEnvironment(b *Bot, seed interface{}) (*Environment, error) {
 	var err error
 	env := &Environment{
-		Bot: b,
+		Bot:     b,
+		Context: b.context(),
 	}
 	switch s := seed.(type) {
 	case *discordgo.Message:
 		env.Type = Message
 		env.TextMessage = s
 		env.Author = s.Author
-		env.TextChannel, err = b.Session.State.Channel(s.ChannelID)
+		env.TextChannel, err = b.State.Channel(s.ChannelID)
 		if err != nil {
 			return nil, err
 		}
 		if env.TextChannel.Type == discordgo.ChannelTypeGuildText {
-			env.Guild, err = b.Session.State.Guild(env.TextChannel.GuildID)
+			env.Guild, err = b.State.Guild(env.TextChannel.GuildID)
 			if err != nil {
 				return nil, err
 			}
 		}
 	case *discordgo.VoiceState:
-		env.Type = Voicestate
+		return NewEnvironment(b, &VoiceStateChange{VoiceState: s})
+	case *VoiceStateChange:
+		env.Author, err = b.Session.User(s.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if s.PrevChannelID != "" {
+			env.PrevVoiceChannel, err = b.State.Channel(s.PrevChannelID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case s.ChannelID == "":
+			// the channel that was left is where anything happens
+			env.Type = VoiceLeave
+			env.VoiceChannel = env.PrevVoiceChannel
+		case s.PrevChannelID == "":
+			env.Type = Voicestate
+		default:
+			env.Type = VoiceMove
+		}
+		if env.VoiceChannel == nil {
+			env.VoiceChannel, err = b.State.Channel(s.ChannelID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		env.Guild, err = b.State.Guild(env.VoiceChannel.GuildID)
+		if err != nil {
+			return nil, err
+		}
+	case *discordgo.MessageReaction:
+		env.Type = Reaction
+		env.Emoji = s.Emoji.APIName()
 		env.Author, err = b.Session.User(s.UserID)
 		if err != nil {
 			return nil, err
 		}
-		env.VoiceChannel, err = b.Session.State.Channel(s.ChannelID)
+		env.TextChannel, err = b.State.Channel(s.ChannelID)
 		if err != nil {
 			return nil, err
 		}
-		env.Guild, err = b.Session.State.Guild(env.VoiceChannel.GuildID)
+		env.TextMessage, err = b.State.Message(s.ChannelID, s.MessageID)
+		if err != nil {
+			env.TextMessage, err = b.Session.ChannelMessage(s.ChannelID, s.MessageID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if env.TextChannel.Type == discordgo.ChannelTypeGuildText {
+			env.Guild, err = b.State.Guild(env.TextChannel.GuildID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case *Interaction:
+		// a slash command creates the same environment as a command written in a message
+		env.Type = Message
+		env.Interaction = s
+		env.Author = s.Author()
+		env.TextChannel, err = b.State.Channel(s.ChannelID)
+		if err != nil {
+			return nil, err
+		}
+		// the message is filled in with the reply to the slash command and the files attached to it
+		env.TextMessage = &discordgo.Message{
+			ChannelID: s.ChannelID,
+			Author:    env.Author,
+		}
+		if s.GuildID != "" {
+			env.Guild, err = b.State.Guild(s.GuildID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case *discordgo.GuildMemberAdd:
+		env.Type = MemberJoin
+		err = env.resolveMember(s.Member)
+		if err != nil {
+			return nil, err
+		}
+	case *discordgo.GuildMemberRemove:
+		env.Type = MemberLeave
+		err = env.resolveMember(s.Member)
 		if err != nil {
 			return nil, err
 		}
@@ -71,6 +206,31 @@ func NewEnvironment(b *Bot, seed interface{}) (*Environment, error) {
 	return env, nil
 }
 
+// resolveMember fills in the guild of a member that joined or left
+// and the text channel that welcomes and farewells are written to
+// The text channel is the guild's spam channel if one is configured, otherwise the guild's system channel
+func (env *Environment) resolveMember(m *discordgo.Member) (err error) {
+	if m == nil || m.User == nil {
+		return errors.New("No member")
+	}
+	env.Author = m.User
+	env.Guild, err = env.Bot.State.Guild(m.GuildID)
+	if err != nil {
+		return err
+	}
+	channelID := env.Guild.SystemChannelID
+	if prefs, err := env.Bot.Driver.GuildPrefs(env.Guild.ID); err == nil && prefs.SpamChannelID != "" {
+		channelID = prefs.SpamChannelID
+	}
+	if channelID != "" {
+		env.TextChannel, err = env.Bot.State.Channel(channelID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Satisfies is true when an environment meets the requirements defined in a Condition
 // Some conditions are more specific than others
 // Satisfies panics if the regexPhrase in c Condition does not compile
@@ -84,7 +244,8 @@ func (env Environment) Satisfies(c Condition) bool {
 	if env.TextMessage != nil {
 		envPhrase = strings.ToLower(env.TextMessage.Content)
 	}
-	phraseMatch := c.Phrase == "" || envPhrase == c.Phrase
-	regexMatch := c.RegexPhrase == "" || regexp.MustCompile(c.RegexPhrase).MatchString(envPhrase)
-	return typeMatch && guildMatch && userMatch && textChannelMatch && voiceChannelMatch && phraseMatch && regexMatch
+	emojiMatch := c.Emoji == "" || env.Emoji == c.Emoji
+	phraseMatch := c.Phrase == "" || c.MatchMode.Matches(envPhrase, c.Phrase)
+	regexMatch := c.RegexPhrase == "" || (env.TextMessage != nil && regexp.MustCompile(ignoreCase(c.RegexPhrase)).MatchString(env.TextMessage.Content))
+	return typeMatch && guildMatch && userMatch && textChannelMatch && voiceChannelMatch && emojiMatch && phraseMatch && regexMatch
 }
@@ -0,0 +1,399 @@
+// Package fake provides an in-memory discord session so that a bot can run without a network connection.
+// The session records the messages, reactions, and role changes it is asked to make,
+// and serves guilds, channels, members, and roles from a discordgo.State.
+package fake
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jeffreymkabot/aoebot"
+)
+
+// Session is an in-memory implementation of aoebot.Session
+type Session struct {
+	mu       sync.Mutex
+	State    *discordgo.State
+	Self     *discordgo.User
+	Status   string
+	users    map[string]*discordgo.User
+	handlers map[*handler]struct{}
+	lastID   int
+
+	// Messages that were sent, in order
+	Messages []*discordgo.Message
+	// Reactions that were added or removed, in order
+	Reactions []Reaction
+	// RoleChanges that were made to guild members, in order
+	RoleChanges []RoleChange
+	// Requests that were made directly to the discord api, in order
+	Requests []Request
+
+	// OnMessage is called with each message that is sent, if it is set
+	OnMessage func(*discordgo.Message)
+	// OnReaction is called with each reaction that is added or removed, if it is set
+	OnReaction func(Reaction)
+}
+
+var _ aoebot.Session = &Session{}
+
+// Reaction is an emoji that was added to or removed from a message
+type Reaction struct {
+	ChannelID string
+	MessageID string
+	Emoji     string
+	Removed   bool
+}
+
+// RoleChange is a role that was given to or taken from a guild member
+type RoleChange struct {
+	GuildID string
+	UserID  string
+	RoleID  string
+	Removed bool
+}
+
+// Request is a request made with RequestWithBucketID
+type Request struct {
+	Method string
+	URL    string
+	Data   interface{}
+}
+
+type handler struct {
+	fn   reflect.Value
+	once bool
+}
+
+// ErrNotFound is returned when the session is asked about something it has not been given
+var ErrNotFound = errors.New("Not found")
+
+// NewSession creates a fake session for a bot user
+func NewSession(self *discordgo.User) *Session {
+	state := discordgo.NewState()
+	state.User = self
+	state.MaxMessageCount = 100
+	s := &Session{
+		State:    state,
+		Self:     self,
+		users:    make(map[string]*discordgo.User),
+		handlers: make(map[*handler]struct{}),
+	}
+	s.AddUser(self)
+	return s
+}
+
+// AddUser makes a user available to User
+func (s *Session) AddUser(u *discordgo.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[u.ID] = u
+}
+
+// AddGuild adds a guild and its channels, members, and roles to the session's state
+// The guild's members are made available to User
+func (s *Session) AddGuild(g *discordgo.Guild) error {
+	for _, m := range g.Members {
+		if m.User != nil {
+			m.GuildID = g.ID
+			s.AddUser(m.User)
+		}
+	}
+	for _, ch := range g.Channels {
+		ch.GuildID = g.ID
+	}
+	return s.State.GuildAdd(g)
+}
+
+// Emit synchronously calls every handler whose event type matches the event, e.g. *discordgo.MessageCreate
+// Handlers receive a nil *discordgo.Session
+func (s *Session) Emit(event interface{}) {
+	s.mu.Lock()
+	matched := []*handler{}
+	for h := range s.handlers {
+		if h.fn.Type().In(1) == reflect.TypeOf(event) {
+			matched = append(matched, h)
+			if h.once {
+				delete(s.handlers, h)
+			}
+		}
+	}
+	s.mu.Unlock()
+	args := []reflect.Value{reflect.ValueOf((*discordgo.Session)(nil)), reflect.ValueOf(event)}
+	for _, h := range matched {
+		h.fn.Call(args)
+	}
+}
+
+// Sent is the content of the messages that were sent to a channel, in order
+func (s *Session) Sent(channelID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := []string{}
+	for _, m := range s.Messages {
+		if m.ChannelID == channelID {
+			sent = append(sent, m.Content)
+		}
+	}
+	return sent
+}
+
+// Reset forgets the messages, reactions, role changes, and requests that were recorded
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Messages = nil
+	s.Reactions = nil
+	s.RoleChanges = nil
+	s.Requests = nil
+}
+
+func (s *Session) nextID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	return strconv.Itoa(s.lastID)
+}
+
+// Open emits a ready event with the guilds in the session's state
+func (s *Session) Open() error {
+	s.Emit(&discordgo.Ready{
+		User:   s.Self,
+		Guilds: s.State.Guilds,
+	})
+	return nil
+}
+
+func (s *Session) Close() error {
+	return nil
+}
+
+func (s *Session) AddHandler(fn interface{}) func() {
+	return s.addHandler(fn, false)
+}
+
+func (s *Session) AddHandlerOnce(fn interface{}) func() {
+	return s.addHandler(fn, true)
+}
+
+func (s *Session) addHandler(fn interface{}, once bool) func() {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.Type().NumIn() != 2 {
+		return func() {}
+	}
+	h := &handler{fn: v, once: once}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[h] = struct{}{}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.handlers, h)
+	}
+}
+
+func (s *Session) UpdateStatus(idle int, game string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status = game
+	return nil
+}
+
+// RequestWithBucketID records the request
+// A POST to a guild's roles creates a role in the session's state with the requested fields
+// The session's application has the same id as its user, as it does for bots made since 2016,
+// and the reply to an interaction is a new message
+func (s *Session) RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) ([]byte, error) {
+	s.mu.Lock()
+	s.Requests = append(s.Requests, Request{method, urlStr, data})
+	s.mu.Unlock()
+
+	if method == "GET" && strings.HasSuffix(urlStr, "/oauth2/applications/@me") {
+		return json.Marshal(map[string]string{"id": s.Self.ID})
+	}
+	if method == "GET" && strings.HasSuffix(urlStr, "/messages/@original") {
+		return json.Marshal(&discordgo.Message{ID: s.nextID()})
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if method != "POST" || !strings.HasSuffix(urlStr, "/roles") {
+		return body, nil
+	}
+	guildID := strings.TrimSuffix(strings.TrimPrefix(urlStr, discordgo.EndpointGuilds), "/roles")
+	role := &discordgo.Role{}
+	if err := json.Unmarshal(body, role); err != nil {
+		return nil, err
+	}
+	role.ID = s.nextID()
+	if err := s.State.RoleAdd(guildID, role); err != nil {
+		return nil, err
+	}
+	return json.Marshal(role)
+}
+
+func (s *Session) User(userID string) (*discordgo.User, error) {
+	if userID == "@me" {
+		return s.Self, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[userID]; ok {
+		return u, nil
+	}
+	return nil, ErrNotFound
+}
+
+// UserChannelCreate creates a private channel in the session's state
+func (s *Session) UserChannelCreate(recipientID string) (*discordgo.Channel, error) {
+	u, err := s.User(recipientID)
+	if err != nil {
+		return nil, err
+	}
+	ch := &discordgo.Channel{
+		ID:         s.nextID(),
+		Type:       discordgo.ChannelTypeDM,
+		Recipients: []*discordgo.User{u},
+	}
+	return ch, s.State.ChannelAdd(ch)
+}
+
+func (s *Session) GuildMemberRoleAdd(guildID, userID, roleID string) error {
+	return s.changeRole(RoleChange{guildID, userID, roleID, false})
+}
+
+func (s *Session) GuildMemberRoleRemove(guildID, userID, roleID string) error {
+	return s.changeRole(RoleChange{guildID, userID, roleID, true})
+}
+
+func (s *Session) changeRole(rc RoleChange) error {
+	if _, err := s.State.Role(rc.GuildID, rc.RoleID); err != nil {
+		return err
+	}
+	member, err := s.State.Member(rc.GuildID, rc.UserID)
+	if err != nil {
+		return err
+	}
+	roles := []string{}
+	for _, roleID := range member.Roles {
+		if roleID != rc.RoleID {
+			roles = append(roles, roleID)
+		}
+	}
+	if !rc.Removed {
+		roles = append(roles, rc.RoleID)
+	}
+	member.Roles = roles
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RoleChanges = append(s.RoleChanges, rc)
+	return nil
+}
+
+// GuildChannelCreate creates a channel in the session's state
+func (s *Session) GuildChannelCreate(guildID, name string, ctype string) (*discordgo.Channel, error) {
+	ch := &discordgo.Channel{
+		ID:      s.nextID(),
+		GuildID: guildID,
+		Name:    name,
+		Type:    discordgo.ChannelTypeGuildText,
+	}
+	if ctype == "voice" {
+		ch.Type = discordgo.ChannelTypeGuildVoice
+	}
+	return ch, s.State.ChannelAdd(ch)
+}
+
+// ChannelDelete removes a channel from the session's state
+func (s *Session) ChannelDelete(channelID string) (*discordgo.Channel, error) {
+	ch, err := s.State.Channel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	return ch, s.State.ChannelRemove(ch)
+}
+
+func (s *Session) ChannelTyping(channelID string) error {
+	_, err := s.State.Channel(channelID)
+	return err
+}
+
+func (s *Session) ChannelPermissionSet(channelID, targetID, targetType string, allow, deny int) error {
+	ch, err := s.State.Channel(channelID)
+	if err != nil {
+		return err
+	}
+	ch.PermissionOverwrites = append(ch.PermissionOverwrites, &discordgo.PermissionOverwrite{
+		ID:    targetID,
+		Type:  targetType,
+		Allow: allow,
+		Deny:  deny,
+	})
+	return nil
+}
+
+func (s *Session) ChannelMessage(channelID, messageID string) (*discordgo.Message, error) {
+	return s.State.Message(channelID, messageID)
+}
+
+func (s *Session) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: channelID, Content: content})
+}
+
+func (s *Session) ChannelMessageSendTTS(channelID string, content string) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: channelID, Content: content, Tts: true})
+}
+
+func (s *Session) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: channelID, Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+// send records a message by the bot user and adds it to the session's state
+func (s *Session) send(m *discordgo.Message) (*discordgo.Message, error) {
+	if _, err := s.State.Channel(m.ChannelID); err != nil {
+		return nil, err
+	}
+	m.ID = s.nextID()
+	m.Author = s.Self
+	s.mu.Lock()
+	s.Messages = append(s.Messages, m)
+	onMessage := s.OnMessage
+	s.mu.Unlock()
+	if onMessage != nil {
+		onMessage(m)
+	}
+	return m, s.State.MessageAdd(m)
+}
+
+func (s *Session) MessageReactionAdd(channelID, messageID, emojiID string) error {
+	return s.react(Reaction{channelID, messageID, emojiID, false})
+}
+
+func (s *Session) MessageReactionRemove(channelID, messageID, emojiID, userID string) error {
+	return s.react(Reaction{channelID, messageID, emojiID, true})
+}
+
+func (s *Session) react(r Reaction) error {
+	if r.Emoji == "" {
+		return errors.New("Unknown Emoji")
+	}
+	if _, err := s.State.Channel(r.ChannelID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.Reactions = append(s.Reactions, r)
+	onReaction := s.OnReaction
+	s.mu.Unlock()
+	if onReaction != nil {
+		onReaction(r)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package aoebot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Group is a command whose subcommands are chosen by its next argument, e.g. meme add write
+// Groups can be nested
+type Group struct {
+	BaseCommand
+	name        string
+	short       string
+	Subcommands []Command
+}
+
+// NewGroup creates a group of subcommands
+func NewGroup(name string, short string, subcommands ...Command) *Group {
+	return &Group{
+		name:        name,
+		short:       short,
+		Subcommands: subcommands,
+	}
+}
+
+func (g *Group) Name() string {
+	return g.name
+}
+
+func (g *Group) Usage() string {
+	names := []string{}
+	for _, cmd := range g.Subcommands {
+		names = append(names, cmd.Name())
+	}
+	return fmt.Sprintf("%s [%s]", g.name, strings.Join(names, " | "))
+}
+
+func (g *Group) Short() string {
+	return g.short
+}
+
+func (g *Group) Long() string {
+	return g.short + ".  Use one of the subcommands."
+}
+
+// findCommand finds the command with a name or alias among commands
+func findCommand(commands []Command, candidate string) (Command, bool) {
+	for _, cmd := range commands {
+		if matchesNameOrAlias(cmd, candidate) {
+			return cmd, true
+		}
+	}
+	return nil, false
+}
+
+// findAlias finds a subcommand nested in any group with an alias
+// findAlias lets subcommands keep the names they had before they were grouped, e.g. addwrite for meme add write
+func findAlias(commands []Command, candidate string) (path []Command, ok bool) {
+	for _, cmd := range commands {
+		g, isGroup := cmd.(*Group)
+		if !isGroup {
+			continue
+		}
+		for _, sub := range g.Subcommands {
+			for _, alias := range sub.Aliases() {
+				if alias == candidate {
+					return []Command{g, sub}, true
+				}
+			}
+		}
+		if subpath, ok := findAlias(g.Subcommands, candidate); ok {
+			return append([]Command{g}, subpath...), true
+		}
+	}
+	return nil, false
+}
+
+// resolve finds the command named by the leading arguments, descending into groups
+// resolve returns the path of commands from the top level to the command, and the remaining arguments
+func resolve(commands []Command, args []string) (path []Command, rest []string) {
+	if len(args) == 0 {
+		return nil, args
+	}
+	candidate := strings.ToLower(args[0])
+	if cmd, ok := findCommand(commands, candidate); ok {
+		path = []Command{cmd}
+	} else if path, ok = findAlias(commands, candidate); !ok {
+		return nil, args
+	}
+	rest = args[1:]
+	for len(rest) > 0 {
+		g, ok := path[len(path)-1].(*Group)
+		if !ok {
+			break
+		}
+		sub, ok := findCommand(g.Subcommands, strings.ToLower(rest[0]))
+		if !ok {
+			break
+		}
+		path = append(path, sub)
+		rest = rest[1:]
+	}
+	return path, rest
+}
+
+// pathName is the full name of the last command in a path, e.g. meme add write
+func pathName(path []Command) string {
+	names := []string{}
+	for _, cmd := range path {
+		names = append(names, cmd.Name())
+	}
+	return strings.Join(names, " ")
+}
+
+// pathUsage is the usage line of the last command in a path, including the names of its groups
+func pathUsage(path []Command) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(pathName(path[:len(path)-1]) + " " + Usage(path[len(path)-1]))
+}
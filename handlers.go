@@ -1,8 +1,8 @@
 package aoebot
 
 import (
+	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -21,6 +21,10 @@ func (b *Bot) onReady() func(s *discordgo.Session, r *discordgo.Ready) {
 		b.addHandler(b.onGuildCreate())
 		b.addHandler(b.onMessageCreate())
 		b.addHandler(b.onVoiceStateUpdate())
+		b.addHandler(b.onMessageReactionAdd())
+		b.addHandler(b.onGuildMemberAdd())
+		b.addHandler(b.onGuildMemberRemove())
+		b.addHandler(b.onEvent())
 		b.Session.UpdateStatus(0, b.Config.Prefix+" "+(&Help{}).Name())
 	}
 }
@@ -35,20 +39,22 @@ func (b *Bot) onGuildCreate() func(*discordgo.Session, *discordgo.GuildCreate) {
 func (b *Bot) registerGuild(g *discordgo.Guild) {
 	log.Printf("Register guild %v", g.Name)
 	b.speakTo(g)
+	if err := b.registerApplicationCommands(g.ID); err != nil {
+		log.Printf("Error registering slash commands in guild %v: %v", g.Name, err)
+	}
 	for _, vs := range g.VoiceStates {
-		// TODO bots could be in a channel in multiple guilds
-		b.occupancy[vs.UserID] = vs.ChannelID
+		b.occupancy.move(g.ID, vs.UserID, vs.ChannelID)
 	}
 	// restore management of any voice channels recovered from db
 	channels := b.Driver.ChannelsGuild(g.ID)
 	if len(channels) > 0 {
 		log.Printf("Restore management of channels %v", channels)
-		delete := func(ch channel) {
+		delete := func(ch ManagedChannel) {
 			log.Printf("Deleting channel %v", ch.Channel.Name)
 			b.Session.ChannelDelete(ch.Channel.ID)
 			b.Driver.ChannelDelete(ch.Channel.ID)
 		}
-		isEmpty := func(ch channel) bool {
+		isEmpty := func(ch ManagedChannel) bool {
 			for _, v := range g.VoiceStates {
 				if v.ChannelID == ch.Channel.ID {
 					return false
@@ -83,16 +89,84 @@ func (b *Bot) onMessageCreate() func(*discordgo.Session, *discordgo.MessageCreat
 			return
 		}
 
-		if strings.HasPrefix(env.TextMessage.Content, b.Config.Prefix) {
-			args := strings.Fields(strings.TrimSpace(strings.TrimPrefix(env.TextMessage.Content, b.Config.Prefix)))
-			cmd, args := b.command(args)
-			log.Printf("Exec cmd %v by %s with %v", cmd.Name(), env.Author, args)
-			b.exec(env, cmd, args)
+		if input, ok := b.trimPrefix(env); ok {
+			args, err := Tokenize(input)
+			if err != nil {
+				b.Write(env.TextChannel.ID, fmt.Sprintf("🤔...\n%v", err), false)
+				return
+			}
+			path, args := b.command(args)
+			if len(path) == 0 {
+				log.Printf("Unknown cmd %v by %s", args[0], env.Author)
+				env.Reply(fmt.Sprintf("🤔...\n%v", unknownCommand(env, b.commands, nil, args[0])))
+				return
+			}
+			log.Printf("Exec cmd %v by %s with %v", pathName(path), env.Author, args)
+			b.exec(env, path, args)
 		} else {
-			actions := b.Driver.actions(env)
-			log.Printf("Dispatch actions %v", actions)
-			b.dispatch(env, actions...)
+			matches := b.Driver.matches(env)
+			log.Printf("Dispatch matches %v", matches)
+			b.dispatch(env, matches...)
+		}
+	}
+}
+
+func (b *Bot) onEvent() func(*discordgo.Session, *discordgo.Event) {
+	// discordgo does not know about slash commands, so interactions are picked out of the raw gateway events
+	return func(s *discordgo.Session, e *discordgo.Event) {
+		if e.Type != "INTERACTION_CREATE" {
+			return
+		}
+		i, err := parseInteraction(b, e)
+		if err != nil {
+			log.Printf("Error parsing interaction: %v", err)
+			return
 		}
+		b.onInteraction(i)
+	}
+}
+
+func (b *Bot) onInteraction(i *Interaction) {
+	// Create a context around a slash command the same way as a command written in a message
+	// Answer with interaction replies, or suggest values while the slash command is typed
+	env, err := NewEnvironment(b, i)
+	if err != nil {
+		log.Printf("Error resolving interaction context: %v", err)
+		return
+	}
+	path, options := resolveInteraction(b.commands, i.Data.InteractionOption)
+	if len(path) == 0 {
+		log.Printf("Saw an interaction for unknown command %v", i.Data.Name)
+		return
+	}
+	cmd := path[len(path)-1]
+
+	switch i.Type {
+	case interactionAutocomplete:
+		choices := completions(env, cmd, options)
+		if err := i.callback(callbackAutocomplete, map[string]interface{}{"choices": choices}); err != nil {
+			log.Printf("Error suggesting values for %v: %v", pathName(path), err)
+		}
+	case interactionCommand:
+		// the bot is thinking until it replies
+		if err := i.callback(callbackDeferredMessage, nil); err != nil {
+			log.Printf("Error responding to interaction %v: %v", pathName(path), err)
+			return
+		}
+		// the slash command has no message of its own, so reactions go on the reply that shows the bot is thinking
+		if msg, err := i.original(); err == nil {
+			env.TextMessage.ID = msg.ID
+		} else {
+			log.Printf("Error finding reply to interaction %v: %v", pathName(path), err)
+		}
+		env.TextMessage.Attachments = i.attachments(options)
+		args, err := interactionArgs(cmd, options)
+		if err != nil {
+			env.Reply(fmt.Sprintf("🤔...\n%v", err))
+			return
+		}
+		log.Printf("Exec slash cmd %v by %s with %v", pathName(path), env.Author, args)
+		b.exec(env, path, args)
 	}
 }
 
@@ -102,31 +176,94 @@ func (b *Bot) onVoiceStateUpdate() func(*discordgo.Session, *discordgo.VoiceStat
 	// Function signature needs to be exact to be detected as the right event handler by discordgo
 	// Access b Bot through a closure
 	return func(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
-		userID := v.VoiceState.UserID
-		channelID := v.VoiceState.ChannelID
-
-		occupancy := b.occupancy[userID]
-		if occupancy != channelID {
-			b.occupancy[userID] = channelID
-			if channelID == "" {
-				return
-			}
+		if v.VoiceState == nil {
+			return
+		}
+		// mute, deafen, etc. also update a voice state without changing its channel
+		prevChannelID := b.occupancy.move(v.GuildID, v.UserID, v.ChannelID)
+		if prevChannelID == v.ChannelID {
+			return
+		}
 
-			env, err := NewEnvironment(b, v.VoiceState)
-			if err != nil {
-				log.Printf("Error resolving voice state context: %v", err)
-				return
-			}
+		env, err := NewEnvironment(b, &VoiceStateChange{v.VoiceState, prevChannelID})
+		if err != nil {
+			log.Printf("Error resolving voice state context: %v", err)
+			return
+		}
+		switch env.Type {
+		case VoiceLeave:
+			log.Printf("Saw user %s leave the voice channel %v in guild %v", env.Author, env.VoiceChannel.Name, env.Guild.Name)
+		case VoiceMove:
+			log.Printf("Saw user %s move from the voice channel %v to %v in guild %v", env.Author, env.PrevVoiceChannel.Name, env.VoiceChannel.Name, env.Guild.Name)
+		default:
 			log.Printf("Saw user %s join the voice channel %v in guild %v", env.Author, env.VoiceChannel.Name, env.Guild.Name)
-			if b.IsOwnEnvironment(env) {
-				return
-			}
+		}
+		if b.IsOwnEnvironment(env) {
+			return
+		}
+
+		matches := b.Driver.matches(env)
+		log.Printf("Found matches %v", matches)
+		b.dispatch(env, matches...)
+	}
+}
 
-			// %
+func (b *Bot) onMessageReactionAdd() func(*discordgo.Session, *discordgo.MessageReactionAdd) {
+	// Create a context around a reaction when the bot sees someone react to a message
+	// Perform any actions that match that context
+	return func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		if r.MessageReaction == nil {
+			return
+		}
 
-			actions := b.Driver.actions(env)
-			log.Printf("Found actions %v", actions)
-			b.dispatch(env, actions...)
+		env, err := NewEnvironment(b, r.MessageReaction)
+		if err != nil {
+			log.Printf("Error resolving reaction context: %v", err)
+			return
 		}
+		log.Printf("Saw user %s react %v in channel %v", env.Author, env.Emoji, env.TextChannel.Name)
+		if env.Author.Bot || b.IsOwnEnvironment(env) {
+			return
+		}
+
+		matches := b.Driver.matches(env)
+		log.Printf("Found matches %v", matches)
+		b.dispatch(env, matches...)
 	}
 }
+
+func (b *Bot) onGuildMemberAdd() func(*discordgo.Session, *discordgo.GuildMemberAdd) {
+	// Create a context around a member when the bot sees them join a guild
+	// Perform any actions that match that context, e.g. a welcome
+	return func(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+		b.onMember(m)
+	}
+}
+
+func (b *Bot) onGuildMemberRemove() func(*discordgo.Session, *discordgo.GuildMemberRemove) {
+	// Create a context around a member when the bot sees them leave a guild
+	// Perform any actions that match that context, e.g. a farewell
+	return func(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+		b.onMember(m)
+	}
+}
+
+func (b *Bot) onMember(seed interface{}) {
+	env, err := NewEnvironment(b, seed)
+	if err != nil {
+		log.Printf("Error resolving member context: %v", err)
+		return
+	}
+	log.Printf("Saw user %s join or leave guild %v", env.Author, env.Guild.Name)
+	if b.IsOwnEnvironment(env) {
+		return
+	}
+	if env.TextChannel == nil {
+		log.Printf("No channel to greet members of guild %v", env.Guild.Name)
+		return
+	}
+
+	matches := b.Driver.matches(env)
+	log.Printf("Found matches %v", matches)
+	b.dispatch(env, matches...)
+}
@@ -0,0 +1,161 @@
+package aoebot
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// conditionIndex is an in-memory index of enabled conditions
+// Conditions are keyed by their environment type, guild, and phrase so that
+// the conditions an environment could satisfy can be found without a database query
+// Conditions whose phrase does not need to match the entire message are found with
+// one automaton per environment type and guild
+type conditionIndex struct {
+	conditions map[indexKey][]*indexedCondition
+	matchers   map[indexKey]*phraseMatcher
+}
+
+type indexKey struct {
+	Type    EnvironmentType
+	GuildID string
+	Phrase  string
+}
+
+// phraseMatcher finds all the conditions whose phrase occurs in a message in one pass
+type phraseMatcher struct {
+	automaton *ahoCorasick
+	// conditions[i] share the phrase automaton.patterns[i]
+	conditions [][]*indexedCondition
+}
+
+// indexedCondition holds a condition alongside its compiled regex phrase
+type indexedCondition struct {
+	Condition
+	regex *regexp.Regexp
+}
+
+func newConditionIndex(conditions []Condition) *conditionIndex {
+	idx := &conditionIndex{
+		conditions: make(map[indexKey][]*indexedCondition),
+		matchers:   make(map[indexKey]*phraseMatcher),
+	}
+	partial := make(map[indexKey]map[string][]*indexedCondition)
+	for _, c := range conditions {
+		ic := &indexedCondition{Condition: c}
+		if c.RegexPhrase != "" {
+			re, err := regexp.Compile(ignoreCase(c.RegexPhrase))
+			if err != nil {
+				log.Printf("Skip indexing condition %v: %v", c.GeneratedName(), err)
+				continue
+			}
+			ic.regex = re
+		} else if c.MatchMode != MatchExact && c.Phrase != "" {
+			key := indexKey{
+				Type:    c.EnvironmentType,
+				GuildID: c.GuildID,
+			}
+			if partial[key] == nil {
+				partial[key] = make(map[string][]*indexedCondition)
+			}
+			partial[key][c.Phrase] = append(partial[key][c.Phrase], ic)
+			continue
+		}
+		key := indexKey{
+			Type:    c.EnvironmentType,
+			GuildID: c.GuildID,
+			Phrase:  c.Phrase,
+		}
+		idx.conditions[key] = append(idx.conditions[key], ic)
+	}
+	for key, byPhrase := range partial {
+		pm := &phraseMatcher{}
+		phrases := []string{}
+		for phrase, ics := range byPhrase {
+			phrases = append(phrases, phrase)
+			pm.conditions = append(pm.conditions, ics)
+		}
+		pm.automaton = newAhoCorasick(phrases)
+		idx.matchers[key] = pm
+	}
+	log.Printf("Indexed %v conditions", len(conditions))
+	return idx
+}
+
+// lookup finds the conditions that are satisfied by an environment
+func (idx *conditionIndex) lookup(env *Environment) []*indexedCondition {
+	guildID := ""
+	if env.Guild != nil {
+		guildID = env.Guild.ID
+	}
+	phrase := ""
+	if env.TextMessage != nil {
+		phrase = strings.ToLower(env.TextMessage.Content)
+	}
+
+	// a condition without a guild or phrase applies to any guild or phrase
+	keys := []indexKey{{env.Type, "", ""}}
+	if guildID != "" {
+		keys = append(keys, indexKey{env.Type, guildID, ""})
+	}
+	if phrase != "" {
+		keys = append(keys, indexKey{env.Type, "", phrase})
+	}
+	if guildID != "" && phrase != "" {
+		keys = append(keys, indexKey{env.Type, guildID, phrase})
+	}
+
+	matches := []*indexedCondition{}
+	for _, key := range keys {
+		for _, ic := range idx.conditions[key] {
+			if ic.satisfiedBy(env) {
+				matches = append(matches, ic)
+			}
+		}
+	}
+	if phrase == "" {
+		return matches
+	}
+	for _, key := range keys {
+		if key.Phrase != "" {
+			continue
+		}
+		if pm, ok := idx.matchers[key]; ok {
+			for _, ic := range pm.lookup(phrase) {
+				if ic.satisfiedBy(env) {
+					matches = append(matches, ic)
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// lookup finds the conditions with a phrase that occurs in text according to their match mode
+// each condition is found at most once
+func (pm *phraseMatcher) lookup(text string) []*indexedCondition {
+	found := make(map[*indexedCondition]struct{})
+	matches := []*indexedCondition{}
+	for _, m := range pm.automaton.findAll(text) {
+		for _, ic := range pm.conditions[m.pattern] {
+			if _, ok := found[ic]; ok {
+				continue
+			}
+			if ic.MatchMode.matchesAt(text, m.start, m.end) {
+				found[ic] = struct{}{}
+				matches = append(matches, ic)
+			}
+		}
+	}
+	return matches
+}
+
+// satisfiedBy checks the requirements of a condition that are not part of its index key
+func (ic *indexedCondition) satisfiedBy(env *Environment) bool {
+	userMatch := ic.UserID == "" || (env.Author != nil && env.Author.ID == ic.UserID)
+	textChannelMatch := ic.TextChannelID == "" || (env.TextChannel != nil && env.TextChannel.ID == ic.TextChannelID)
+	voiceChannelMatch := ic.VoiceChannelID == "" || (env.VoiceChannel != nil && env.VoiceChannel.ID == ic.VoiceChannelID)
+	emojiMatch := ic.Emoji == "" || env.Emoji == ic.Emoji
+	regexMatch := ic.regex == nil || (env.TextMessage != nil && ic.regex.MatchString(env.TextMessage.Content))
+	return userMatch && textChannelMatch && voiceChannelMatch && emojiMatch && regexMatch
+}
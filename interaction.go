@@ -0,0 +1,531 @@
+package aoebot
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// discordgo predates slash commands, so they are described and answered through the REST API directly
+const interactionsAPI = "https://discord.com/api/v10/"
+
+// interaction types
+const (
+	interactionCommand      = 2
+	interactionAutocomplete = 4
+)
+
+// interaction callback types
+const (
+	callbackDeferredMessage = 5
+	callbackAutocomplete    = 8
+)
+
+// option types of an application command
+const (
+	optionSubcommand      = 1
+	optionSubcommandGroup = 2
+	optionString          = 3
+	optionInteger         = 4
+	optionBoolean         = 5
+	optionUser            = 6
+	optionChannel         = 7
+	optionRole            = 8
+	optionAttachment      = 11
+)
+
+// the option a file is attached to a slash command with
+const attachmentOption = "file"
+
+// discord limits the length of a description of a command or option
+const maxDescriptionLength = 100
+
+// Interaction is a slash command used by a member or an autocomplete request while they type one
+type Interaction struct {
+	ID        string            `json:"id"`
+	Type      int               `json:"type"`
+	GuildID   string            `json:"guild_id"`
+	ChannelID string            `json:"channel_id"`
+	Member    *discordgo.Member `json:"member"`
+	User      *discordgo.User   `json:"user"`
+	Token     string            `json:"token"`
+	Data      InteractionData   `json:"data"`
+
+	bot     *Bot
+	mu      sync.Mutex
+	replied bool
+}
+
+// InteractionData is the slash command that was used, with the files that were attached to it
+type InteractionData struct {
+	InteractionOption
+	Resolved struct {
+		Attachments map[string]*discordgo.MessageAttachment `json:"attachments"`
+	} `json:"resolved"`
+}
+
+// InteractionOption is the name of a slash command or one of its options, with the value that was typed
+type InteractionOption struct {
+	Name    string              `json:"name"`
+	Type    int                 `json:"type"`
+	Value   interface{}         `json:"value"`
+	Focused bool                `json:"focused"`
+	Options []InteractionOption `json:"options"`
+}
+
+// Author is the user who used the interaction, a member in a guild or a user in a DM
+func (i *Interaction) Author() *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// Completer is a command that suggests values of an option as a member types a slash command
+type Completer interface {
+	Complete(env *Environment, option string, partial string) []string
+}
+
+// Uploader is a command that reads a file attached to its message
+// Its slash command has an option to attach the file, which Upload describes
+type Uploader interface {
+	Upload() string
+}
+
+// applicationCommand describes a command to discord as a slash command
+type applicationCommand struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Options     []applicationCommandOption `json:"options,omitempty"`
+}
+
+type applicationCommandOption struct {
+	Type         int                        `json:"type"`
+	Name         string                     `json:"name"`
+	Description  string                     `json:"description"`
+	Required     bool                       `json:"required,omitempty"`
+	Autocomplete bool                       `json:"autocomplete,omitempty"`
+	Choices      []optionChoice             `json:"choices,omitempty"`
+	Options      []applicationCommandOption `json:"options,omitempty"`
+}
+
+type optionChoice struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// applicationCommands describes every command that is not owner only
+func applicationCommands(commands []Command) []applicationCommand {
+	defs := []applicationCommand{}
+	for _, cmd := range commands {
+		if cmd.IsOwnerOnly() {
+			continue
+		}
+		defs = append(defs, applicationCommand{
+			Name:        cmd.Name(),
+			Description: description(cmd.Short(), cmd.Name()),
+			Options:     commandOptions(cmd, 0),
+		})
+	}
+	return defs
+}
+
+// commandOptions describes the subcommands of a group, or the flags and arguments of a command
+// discord only allows a slash command to nest groups one level deep
+func commandOptions(cmd Command, depth int) []applicationCommandOption {
+	if g, ok := cmd.(*Group); ok {
+		options := []applicationCommandOption{}
+		for _, sub := range g.Subcommands {
+			_, isGroup := sub.(*Group)
+			if sub.IsOwnerOnly() || (isGroup && depth > 0) {
+				continue
+			}
+			option := applicationCommandOption{
+				Type:        optionSubcommand,
+				Name:        sub.Name(),
+				Description: description(sub.Short(), sub.Name()),
+				Options:     commandOptions(sub, depth+1),
+			}
+			if isGroup {
+				option.Type = optionSubcommandGroup
+			}
+			options = append(options, option)
+		}
+		return options
+	}
+
+	_, autocomplete := cmd.(Completer)
+	// discord wants required options before optional ones
+	required, optional := []applicationCommandOption{}, []applicationCommandOption{}
+	if u, ok := cmd.(Uploader); ok {
+		required = append(required, applicationCommandOption{
+			Type:        optionAttachment,
+			Name:        attachmentOption,
+			Description: description(u.Upload(), attachmentOption),
+			Required:    true,
+		})
+	}
+	ac, ok := cmd.(ArgsCommand)
+	if !ok {
+		if len(strings.Fields(cmd.Usage())) < 2 {
+			return nil
+		}
+		// commands without a schema take their arguments as they would be written in a message
+		return append(required, applicationCommandOption{
+			Type:         optionString,
+			Name:         "args",
+			Description:  description(cmd.Usage(), "arguments"),
+			Autocomplete: autocomplete,
+		})
+	}
+	schema := ac.Schema()
+	for _, a := range schema.Args {
+		if a.Type == ArgLiteral {
+			continue
+		}
+		option := applicationCommandOption{
+			Type:         optionType(a.Type),
+			Name:         a.Name,
+			Description:  a.argUsage(),
+			Required:     !a.Optional,
+			Autocomplete: autocomplete && optionType(a.Type) == optionString,
+		}
+		if option.Required {
+			required = append(required, option)
+		} else {
+			optional = append(optional, option)
+		}
+	}
+	for _, f := range schema.Flags {
+		option := applicationCommandOption{
+			Type:        optionType(f.Type),
+			Name:        f.Name,
+			Description: description(f.Usage, f.Name),
+		}
+		for _, choice := range f.Choices {
+			option.Choices = append(option.Choices, optionChoice{Name: choice, Value: choice})
+		}
+		optional = append(optional, option)
+	}
+	return append(required, optional...)
+}
+
+func optionType(t ArgType) int {
+	switch t {
+	case ArgInt:
+		return optionInteger
+	case ArgBool:
+		return optionBoolean
+	case ArgUser:
+		return optionUser
+	case ArgChannel:
+		return optionChannel
+	case ArgRole:
+		return optionRole
+	}
+	return optionString
+}
+
+func description(desc string, fallback string) string {
+	if desc == "" {
+		desc = fallback
+	}
+	if runes := []rune(desc); len(runes) > maxDescriptionLength {
+		desc = string(runes[:maxDescriptionLength-3]) + "..."
+	}
+	return desc
+}
+
+// applicationID looks up the id of the application the bot belongs to, which slash commands are registered and answered under
+func (b *Bot) applicationID() (string, error) {
+	url := interactionsAPI + "oauth2/applications/@me"
+	resp, err := b.Session.RequestWithBucketID("GET", url, nil, url)
+	if err != nil {
+		return "", err
+	}
+	app := struct {
+		ID string `json:"id"`
+	}{}
+	if err := json.Unmarshal(resp, &app); err != nil {
+		return "", err
+	}
+	if app.ID == "" {
+		return "", errors.New("No application id")
+	}
+	return app.ID, nil
+}
+
+// registerApplicationCommands replaces the slash commands of a guild with the bot's commands
+// the commands are only sent again when they have changed since they were last registered in the guild,
+// since discord sends a guild create for every guild each time the bot reconnects
+func (b *Bot) registerApplicationCommands(guildID string) error {
+	if b.appID == "" {
+		return nil
+	}
+	commands := applicationCommands(b.commands)
+	body, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+	if b.registered.same(guildID, body) {
+		return nil
+	}
+	url := fmt.Sprintf("%sapplications/%s/guilds/%s/commands", interactionsAPI, b.appID, guildID)
+	if _, err := b.Session.RequestWithBucketID("PUT", url, commands, url); err != nil {
+		return err
+	}
+	b.registered.set(guildID, body)
+	return nil
+}
+
+// registeredCommands remembers the slash commands last registered in each guild
+type registeredCommands struct {
+	mu      sync.Mutex
+	byGuild map[string][sha256.Size]byte
+}
+
+func newRegisteredCommands() *registeredCommands {
+	return &registeredCommands{
+		byGuild: make(map[string][sha256.Size]byte),
+	}
+}
+
+// same is true if body is what was last registered in a guild
+func (rc *registeredCommands) same(guildID string, body []byte) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	sum, ok := rc.byGuild[guildID]
+	return ok && sum == sha256.Sum256(body)
+}
+
+func (rc *registeredCommands) set(guildID string, body []byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.byGuild[guildID] = sha256.Sum256(body)
+}
+
+// resolveInteraction finds the path of commands named by a slash command and the options of the last command
+func resolveInteraction(commands []Command, data InteractionOption) (path []Command, options []InteractionOption) {
+	cmd, ok := findCommand(commands, data.Name)
+	if !ok {
+		return nil, nil
+	}
+	path = []Command{cmd}
+	options = data.Options
+	for len(options) == 1 && (options[0].Type == optionSubcommand || options[0].Type == optionSubcommandGroup) {
+		g, ok := path[len(path)-1].(*Group)
+		if !ok {
+			break
+		}
+		sub, ok := findCommand(g.Subcommands, options[0].Name)
+		if !ok {
+			return nil, nil
+		}
+		path = append(path, sub)
+		options = options[0].Options
+	}
+	return path, options
+}
+
+// attachments finds the files attached to the options of a slash command
+func (i *Interaction) attachments(options []InteractionOption) []*discordgo.MessageAttachment {
+	attachments := []*discordgo.MessageAttachment{}
+	for _, option := range options {
+		if option.Type != optionAttachment {
+			continue
+		}
+		if a, ok := i.Data.Resolved.Attachments[optionValue(option.Value)]; ok {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments
+}
+
+// interactionArgs writes the options of a slash command as the arguments of a message, so they are parsed the same way
+func interactionArgs(cmd Command, options []InteractionOption) ([]string, error) {
+	values := make(map[string]string)
+	for _, option := range options {
+		values[option.Name] = optionValue(option.Value)
+	}
+	ac, ok := cmd.(ArgsCommand)
+	if !ok {
+		return Tokenize(values["args"])
+	}
+	schema := ac.Schema()
+	args := []string{}
+	for _, f := range schema.Flags {
+		if v, ok := values[f.Name]; ok {
+			args = append(args, "-"+f.Name+"="+v)
+		}
+	}
+	// a value that starts with a dash is an argument, not a flag
+	args = append(args, "--")
+	for _, a := range schema.Args {
+		v, ok := values[a.Name]
+		switch {
+		case a.Type == ArgLiteral:
+			args = append(args, a.Name)
+		case !ok:
+			// the rest of the arguments are optional too
+			return args, nil
+		case a.Variadic:
+			rest, err := Tokenize(v)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, rest...)
+		default:
+			args = append(args, v)
+		}
+	}
+	return args, nil
+}
+
+func optionValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// focused finds the option a member is typing
+func focused(options []InteractionOption) (InteractionOption, bool) {
+	for _, option := range options {
+		if option.Focused {
+			return option, true
+		}
+	}
+	return InteractionOption{}, false
+}
+
+// callback responds to an interaction for the first time
+func (i *Interaction) callback(callbackType int, data interface{}) error {
+	url := fmt.Sprintf("%sinteractions/%s/%s/callback", interactionsAPI, i.ID, i.Token)
+	body := map[string]interface{}{"type": callbackType}
+	if data != nil {
+		body["data"] = data
+	}
+	_, err := i.bot.Session.RequestWithBucketID("POST", url, body, interactionsAPI+"interactions")
+	return err
+}
+
+// interactionReply is a message sent in response to a slash command
+type interactionReply struct {
+	Content string                    `json:"content,omitempty"`
+	Embeds  []*discordgo.MessageEmbed `json:"embeds,omitempty"`
+}
+
+// reply sends a message in response to a slash command
+// The first reply replaces the message that shows the bot is thinking and later replies follow up on it
+func (i *Interaction) reply(content string, embeds ...*discordgo.MessageEmbed) error {
+	msg := interactionReply{Content: content, Embeds: embeds}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	url := fmt.Sprintf("%swebhooks/%s/%s", interactionsAPI, i.bot.appID, i.Token)
+	method := "POST"
+	if !i.replied {
+		url += "/messages/@original"
+		method = "PATCH"
+	}
+	_, err := i.bot.Session.RequestWithBucketID(method, url, msg, interactionsAPI+"webhooks/"+i.bot.appID)
+	if err == nil {
+		i.replied = true
+	}
+	return err
+}
+
+// original finds the message that replies to a slash command, which shows the bot is thinking until the first reply
+func (i *Interaction) original() (*discordgo.Message, error) {
+	url := fmt.Sprintf("%swebhooks/%s/%s/messages/@original", interactionsAPI, i.bot.appID, i.Token)
+	resp, err := i.bot.Session.RequestWithBucketID("GET", url, nil, interactionsAPI+"webhooks/"+i.bot.appID)
+	if err != nil {
+		return nil, err
+	}
+	msg := &discordgo.Message{}
+	if err := json.Unmarshal(resp, msg); err != nil {
+		return nil, err
+	}
+	if msg.ID == "" {
+		return nil, errors.New("No original message")
+	}
+	return msg, nil
+}
+
+// hasReplied is true after the interaction has been replied to
+func (i *Interaction) hasReplied() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.replied
+}
+
+// parseInteraction decodes an interaction from a raw gateway event
+func parseInteraction(b *Bot, e *discordgo.Event) (*Interaction, error) {
+	if e.Type != "INTERACTION_CREATE" {
+		return nil, errors.New("Not an interaction")
+	}
+	i := &Interaction{bot: b}
+	if err := json.Unmarshal(e.RawData, i); err != nil {
+		return nil, err
+	}
+	if i.Author() == nil {
+		return nil, errors.New("Interaction has no user")
+	}
+	return i, nil
+}
+
+// ackOrDefault is the reply to a slash command that succeeds without replying
+func ackOrDefault(ack string) string {
+	if ack == "" {
+		return "✅"
+	}
+	return ack
+}
+
+// completions are the values suggested for the option a member is typing
+func completions(env *Environment, cmd Command, options []InteractionOption) []optionChoice {
+	choices := []optionChoice{}
+	c, ok := cmd.(Completer)
+	if !ok {
+		return choices
+	}
+	option, ok := focused(options)
+	if !ok {
+		return choices
+	}
+	for _, value := range c.Complete(env, option.Name, optionValue(option.Value)) {
+		// discord only shows 25 choices
+		if len(choices) == 25 {
+			break
+		}
+		choices = append(choices, optionChoice{Name: description(value, value), Value: value})
+	}
+	return choices
+}
+
+// CompleteLast completes the last word of a partially typed list of words with each candidate that it starts
+func CompleteLast(partial string, candidates []string) []string {
+	words := strings.Fields(partial)
+	last := ""
+	if len(words) > 0 && !strings.HasSuffix(partial, " ") {
+		last = strings.ToLower(words[len(words)-1])
+		words = words[:len(words)-1]
+	}
+	completed := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, last) {
+			completed = append(completed, strings.Join(append(words, candidate), " "))
+		}
+	}
+	return completed
+}
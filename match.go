@@ -0,0 +1,93 @@
+package aoebot
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MatchMode is how a Condition's phrase is compared to the content of a message
+// The zero value requires the phrase to match the entire message
+type MatchMode string
+
+const (
+	MatchExact    MatchMode = ""
+	MatchContains MatchMode = "contains"
+	MatchPrefix   MatchMode = "prefix"
+	MatchWord     MatchMode = "word"
+)
+
+// String implements flag.Value
+func (m MatchMode) String() string {
+	return string(m)
+}
+
+// Set implements flag.Value
+func (m *MatchMode) Set(s string) error {
+	switch mode := MatchMode(strings.ToLower(s)); mode {
+	case MatchExact, MatchContains, MatchPrefix, MatchWord:
+		*m = mode
+		return nil
+	}
+	return fmt.Errorf("Unsupported match mode %v, try contains, prefix, or word", s)
+}
+
+// Matches is true when text contains phrase in the way described by the MatchMode
+func (m MatchMode) Matches(text string, phrase string) bool {
+	switch m {
+	case MatchContains:
+		return strings.Contains(text, phrase)
+	case MatchPrefix:
+		return strings.HasPrefix(text, phrase)
+	case MatchWord:
+		for offset := 0; offset <= len(text)-len(phrase); {
+			i := strings.Index(text[offset:], phrase)
+			if i < 0 {
+				return false
+			}
+			start := offset + i
+			if isWordBoundary(text, start, start+len(phrase)) {
+				return true
+			}
+			_, size := utf8.DecodeRuneInString(text[start:])
+			offset = start + size
+		}
+		return false
+	}
+	return text == phrase
+}
+
+// matchesAt is true when an occurrence of a phrase at text[start:end] satisfies the MatchMode
+func (m MatchMode) matchesAt(text string, start int, end int) bool {
+	switch m {
+	case MatchContains:
+		return true
+	case MatchPrefix:
+		return start == 0
+	case MatchWord:
+		return isWordBoundary(text, start, end)
+	}
+	return start == 0 && end == len(text)
+}
+
+// isWordBoundary is true when text[start:end] is not part of a larger word
+func isWordBoundary(text string, start int, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -0,0 +1,376 @@
+package aoebot
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// mongoStore is a Store backed by a MongoDB session
+// Actions are discovered as subdocments of entries in the "conditions" collection
+type mongoStore struct {
+	*mgo.Session
+}
+
+// DialMongo connects to a MongoDB server
+func DialMongo(dbURL string) StoreDialer {
+	return func() (Store, error) {
+		session, err := mgo.Dial(dbURL)
+		if err != nil {
+			return nil, err
+		}
+		return &mongoStore{session}, nil
+	}
+}
+
+func (m *mongoStore) ConditionsEnabled() ([]Condition, error) {
+	coll := m.DB("aoebot").C("conditions")
+	query := bson.M{
+		"enabled": true,
+	}
+
+	conditions := []Condition{}
+	err := coll.Find(query).All(&conditions)
+	return conditions, err
+}
+
+func (m *mongoStore) ConditionsEnvironment(env *Environment) []Condition {
+	coll := m.DB("aoebot").C("conditions")
+	query := queryEnvironment(env)
+	log.Printf("Using query %s", query)
+
+	found := []Condition{}
+	err := coll.Find(query).All(&found)
+	if err != nil {
+		log.Printf("Error in query %v", err)
+	}
+	// the query can not match phrases by their match mode, so check the conditions it found the same way as bolt
+	conditions := []Condition{}
+	for _, c := range found {
+		unregexed := c
+		unregexed.RegexPhrase = ""
+		if env.Satisfies(unregexed) {
+			conditions = append(conditions, c)
+		}
+	}
+	return conditions
+}
+
+// ConditionsGuild returns all the custom conditions created through the discord message interface
+// that are exclusive to a particular guild.
+func (m *mongoStore) ConditionsGuild(guildID string) []Condition {
+	coll := m.DB("aoebot").C("conditions")
+	// conditions created through discord message interface always have createdby
+	query := bson.M{
+		"createdby": bson.M{
+			"$exists": true,
+		},
+		"guild":   guildID,
+		"enabled": true,
+	}
+
+	conditions := []Condition{}
+	err := coll.Find(query).All(&conditions)
+	if err != nil {
+		log.Printf("Error in query guild custom conditions %v", err)
+	}
+	log.Printf("Found %v custom conditions for guild", len(conditions))
+	return conditions
+}
+
+func (m *mongoStore) ConditionsTagged(tag string) []Condition {
+	coll := m.DB("aoebot").C("conditions")
+	query := bson.M{
+		"tags": tag,
+	}
+
+	conditions := []Condition{}
+	err := coll.Find(query).All(&conditions)
+	if err != nil {
+		log.Printf("Error in query tagged conditions %v", err)
+	}
+	return conditions
+}
+
+// ConditionAdd inserts a new custom condition for a guild.
+// ConditionAdd overwites an existing condition with the same environment and action to prevent duplication,
+// enabling it if it was disabled.
+func (m *mongoStore) ConditionAdd(c *Condition, creator string) error {
+	coll := m.DB("aoebot").C("conditions")
+	selector := c.identity()
+	info, err := coll.Upsert(selector, bson.M{
+		"$set": bson.M{
+			"name":          c.GeneratedName(),
+			"createdby":     creator,
+			"enabled":       true,
+			"cooldown":      c.Cooldown,
+			"cooldownScope": c.CooldownScope,
+			"chance":        c.Chance,
+		},
+	})
+	if err == nil {
+		log.Printf("added Condition %#v", info)
+	}
+	return err
+}
+
+// ConditionDisable disables a condition and any of its duplicates.
+func (m *mongoStore) ConditionDisable(c *Condition) error {
+	coll := m.DB("aoebot").C("conditions")
+	info, err := coll.UpdateAll(c, bson.M{
+		"$set": bson.M{
+			"enabled": false,
+		},
+	})
+	if err == nil {
+		log.Printf("disabled Condition %#v", info)
+	}
+	return err
+}
+
+// ConditionsSwapClip rewrites the action of each of a guild's custom conditions that says a clip.
+func (m *mongoStore) ConditionsSwapClip(guildID string, oldID string, newID string) error {
+	coll := m.DB("aoebot").C("conditions")
+	query := bson.M{
+		"createdby": bson.M{
+			"$exists": true,
+		},
+		"guild":   guildID,
+		"enabled": true,
+	}
+	docs := []struct {
+		ID        bson.ObjectId `bson:"_id"`
+		Condition `bson:",inline"`
+	}{}
+	if err := coll.Find(query).All(&docs); err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if !swapClip(&doc.Condition, oldID, newID) {
+			continue
+		}
+		err := coll.UpdateId(doc.ID, bson.M{
+			"$set": bson.M{
+				"name":   doc.Name,
+				"action": doc.Action,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Channels retrieves all managed channels registered for any guild.
+func (m *mongoStore) Channels() []ManagedChannel {
+	channels := []ManagedChannel{}
+	coll := m.DB("aoebot").C("channels")
+	err := coll.Find(nil).All(&channels)
+	if err != nil {
+		log.Printf("Error in query managed channels %v", err)
+	}
+	return channels
+}
+
+// ChannelsGuild retrieves all managed channels registered for a particular guild.
+func (m *mongoStore) ChannelsGuild(guildID string) []ManagedChannel {
+	channels := []ManagedChannel{}
+	coll := m.DB("aoebot").C("channels")
+	query := bson.M{
+		"channel.guildid": guildID,
+	}
+	err := coll.Find(query).All(&channels)
+	if err != nil {
+		log.Printf("Error in query guild managed channels %v", err)
+	}
+	return channels
+}
+
+// ChannelsGuild registers a new managed channel.
+// Registered managed channels are recovered when the bot restarts.
+func (m *mongoStore) ChannelAdd(ch ManagedChannel) error {
+	coll := m.DB("aoebot").C("channels")
+	return coll.Insert(ch)
+}
+
+// ChannelDelete unregisters a managed channel.
+// Managed channels that are unregistered are lost when the bot restarts.
+func (m *mongoStore) ChannelDelete(channelID ...string) error {
+	coll := m.DB("aoebot").C("channels")
+	query := bson.M{
+		"channel.id": bson.M{
+			"$in": channelID,
+		},
+	}
+	return coll.Remove(query)
+}
+
+func (m *mongoStore) GuildPrefs(guildID string) (*GuildPrefs, error) {
+	coll := m.DB("aoebot").C("guilds")
+	prefs := &GuildPrefs{GuildID: guildID}
+	err := coll.Find(prefs).One(&prefs)
+	if err == mgo.ErrNotFound {
+		err = ErrNotFound
+	}
+	return prefs, err
+}
+
+// GuildPrefsSet unsets the fields that are empty, since $set skips them and would keep their old values
+func (m *mongoStore) GuildPrefsSet(prefs *GuildPrefs) error {
+	coll := m.DB("aoebot").C("guilds")
+	query := GuildPrefs{GuildID: prefs.GuildID}
+	update := bson.M{"$set": prefs}
+	unset := bson.M{}
+	if prefs.SpamChannelID == "" {
+		unset["spam_channel"] = ""
+	}
+	if len(prefs.GameRoles) == 0 {
+		unset["game_roles"] = ""
+	}
+	if prefs.Prefix == "" {
+		unset["prefix"] = ""
+	}
+	if len(prefs.Permissions) == 0 {
+		unset["permissions"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	info, err := coll.Upsert(query, update)
+	if err == nil {
+		log.Printf("set guild prefs %#v", info)
+	}
+	return err
+}
+
+// db table has a unique index on alias field
+func (m *mongoStore) GameByAlias(alias string) string {
+	coll := m.DB("aoebot").C("games")
+	query := bson.M{"alias": strings.ToLower(alias)}
+	ga := GameAlias{}
+	coll.Find(query).One(&ga)
+	return ga.Game
+}
+
+func (m *mongoStore) GameAliasAdd(ga GameAlias) error {
+	coll := m.DB("aoebot").C("games")
+	_, err := coll.Upsert(bson.M{"alias": ga.Alias}, ga)
+	return err
+}
+
+func (m *mongoStore) Games() (games []string) {
+	coll := m.DB("aoebot").C("games")
+	coll.Find(nil).Distinct("game", &games)
+	sort.Strings(games)
+	return
+}
+
+func (m *mongoStore) Clip(id string) (*Clip, error) {
+	coll := m.DB("aoebot").C("clips")
+	clip := &Clip{}
+	err := coll.FindId(id).One(clip)
+	if err == mgo.ErrNotFound {
+		err = ErrNotFound
+	}
+	return clip, err
+}
+
+func (m *mongoStore) Clips() []Clip {
+	coll := m.DB("aoebot").C("clips")
+	clips := []Clip{}
+	err := coll.Find(nil).All(&clips)
+	if err != nil {
+		log.Printf("Error in query clips %v", err)
+	}
+	return clips
+}
+
+// ClipAdd only inserts fields when the clip is new, so the record of the first upload is kept
+func (m *mongoStore) ClipAdd(clip *Clip) error {
+	coll := m.DB("aoebot").C("clips")
+	_, err := coll.UpsertId(clip.ID, bson.M{
+		"$setOnInsert": bson.M{
+			"name":       clip.Name,
+			"uploadedby": clip.UploadedBy,
+			"guild":      clip.GuildID,
+			"duration":   clip.Duration,
+			"size":       clip.Size,
+		},
+	})
+	return err
+}
+
+func (m *mongoStore) ClipDelete(clipID ...string) error {
+	coll := m.DB("aoebot").C("clips")
+	_, err := coll.RemoveAll(bson.M{
+		"_id": bson.M{
+			"$in": clipID,
+		},
+	})
+	return err
+}
+
+type query bson.M
+
+// make queries pleasant to read in log messages
+func (q query) String() string {
+	queryjson, _ := json.Marshal(q)
+	return string(queryjson)
+}
+
+func queryEnvironment(env *Environment) query {
+	and := []bson.M{
+		bson.M{
+			"enabled": true,
+		},
+	}
+	if env.Guild != nil {
+		and = append(and, emptyOrEqual("guild", env.Guild.ID))
+	}
+	if env.Author != nil {
+		and = append(and, emptyOrEqual("user", env.Author.ID))
+	}
+	if env.TextChannel != nil {
+		and = append(and, emptyOrEqual("textChannel", env.TextChannel.ID))
+	}
+	if env.VoiceChannel != nil {
+		and = append(and, emptyOrEqual("textChannel", env.VoiceChannel.ID))
+	}
+	and = append(and, emptyOrEqual("emoji", env.Emoji))
+	phrase := ""
+	if env.TextMessage != nil {
+		phrase = strings.ToLower(env.TextMessage.Content)
+	}
+	// a phrase that is not matched exactly is checked against the message after the query
+	and = append(and, bson.M{
+		"$or": []bson.M{
+			emptyOrEqual("phrase", phrase),
+			bson.M{
+				"match": bson.M{
+					"$in": []MatchMode{MatchContains, MatchPrefix, MatchWord},
+				},
+			},
+		},
+	})
+	return query(bson.M{
+		"type": env.Type,
+		"$and": and,
+	})
+}
+
+// bson clause { "$in": [value, null] }
+func emptyOrEqual(field string, value interface{}) bson.M {
+	return bson.M{
+		field: bson.M{
+			"$in": []interface{}{
+				value,
+				nil,
+			},
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package aoebot
+
+import "sync"
+
+// occupancy tracks the voice channel that each user is connected to in each guild
+// A user can be connected to a voice channel in more than one guild at a time
+type occupancy struct {
+	mu       sync.Mutex
+	channels map[occupant]string
+}
+
+type occupant struct {
+	GuildID string
+	UserID  string
+}
+
+func newOccupancy() *occupancy {
+	return &occupancy{
+		channels: make(map[occupant]string),
+	}
+}
+
+// move records the voice channel a user is connected to in a guild, empty if none
+// move returns the voice channel the user was connected to before
+func (o *occupancy) move(guildID string, userID string, channelID string) (prevChannelID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	key := occupant{guildID, userID}
+	prevChannelID = o.channels[key]
+	if channelID == "" {
+		delete(o.channels, key)
+	} else {
+		o.channels[key] = channelID
+	}
+	return
+}
@@ -0,0 +1,268 @@
+package aoebot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// adminPermissions are the discord permissions that make a member an admin of a guild
+const adminPermissions = discordgo.PermissionAdministrator | discordgo.PermissionManageServer
+
+// permissions decide which commands the author of an environment may run
+// A guild restricts a command to some roles with a rule on the command's full name, e.g. meme add
+// The rule on the closest group applies to commands that do not have their own rule
+type permissions struct {
+	isOwner bool
+	isAdmin bool
+	roles   map[string]bool
+	rules   map[string][]string
+}
+
+// permissions looks up the roles of the author of an environment and the rules of its guild
+func (b *Bot) permissions(env *Environment) *permissions {
+	p := &permissions{
+		isOwner: env.Author.ID == b.owner,
+		roles:   make(map[string]bool),
+	}
+	// the owner of the bot is an admin everywhere
+	p.isAdmin = p.isOwner || isAdmin(env)
+	if env.Guild == nil {
+		return p
+	}
+	if member, err := b.State.Member(env.Guild.ID, env.Author.ID); err == nil {
+		for _, roleID := range member.Roles {
+			p.roles[roleID] = true
+		}
+	}
+	if prefs, err := b.Driver.GuildPrefs(env.Guild.ID); err == nil {
+		p.rules = prefs.Permissions
+	}
+	return p
+}
+
+// isAdmin is true when the author of an environment can manage its guild
+func isAdmin(env *Environment) bool {
+	if env.Guild == nil || env.TextChannel == nil {
+		return false
+	}
+	perms, err := env.Bot.State.UserChannelPermissions(env.Author.ID, env.TextChannel.ID)
+	return err == nil && perms&adminPermissions != 0
+}
+
+// allow is true when the last command of a path may be run
+func (p *permissions) allow(path []Command) bool {
+	for _, cmd := range path {
+		if cmd.IsOwnerOnly() && !p.isOwner {
+			return false
+		}
+	}
+	if p.isAdmin {
+		return true
+	}
+	// a rule can not let a role run a command for admins
+	for _, cmd := range path {
+		if cmd.IsAdminOnly() {
+			return false
+		}
+	}
+	if roles, ok := p.rule(path); ok {
+		for _, roleID := range roles {
+			if p.roles[roleID] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// rule finds the rule on the last command of a path or on its closest group
+func (p *permissions) rule(path []Command) ([]string, bool) {
+	for i := len(path); i > 0; i-- {
+		if roles, ok := p.rules[pathName(path[:i])]; ok {
+			return roles, true
+		}
+	}
+	return nil, false
+}
+
+type Perms struct {
+	BaseCommand
+}
+
+func (p *Perms) Name() string {
+	return "perms"
+}
+
+func (p *Perms) Aliases() []string {
+	return []string{"permissions"}
+}
+
+func (p *Perms) Schema() Schema {
+	return Schema{
+		Flags: []Flag{
+			{Name: "allow", Type: ArgRole, Usage: "let a role run the command"},
+			{Name: "disallow", Type: ArgRole, Usage: "stop letting a role run the command"},
+			{Name: "reset", Type: ArgBool, Usage: "let anyone run the command"},
+		},
+		Args: []Arg{
+			{Name: "command", Optional: true, Variadic: true},
+		},
+		Exclusive: [][]string{
+			{"allow", "disallow", "reset"},
+		},
+	}
+}
+
+func (p *Perms) Short() string {
+	return "Control who can run my commands"
+}
+
+func (p *Perms) Long() string {
+	return `Show or change which roles can run [command] in this guild.
+Without a [command] I will list the rules for this guild.
+Use the [-allow] flag to let members with a role run [command], and only those members unless another role is allowed.
+Use the [-disallow] flag to take a role off [command], or the [-reset] flag to let anyone run [command] again.
+A rule on a group of commands, e.g. meme add, applies to every command in the group that does not have its own rule.
+Members who can manage this guild can run any command, and only they can change the rules.
+Commands for admins are always only for admins, so they can't be given to a role.`
+}
+
+func (p *Perms) Examples() []string {
+	return []string{
+		"perms",
+		"perms meme add",
+		"perms -allow @Moderators meme",
+		"perms -disallow @Moderators meme del",
+		"perms -reset meme",
+	}
+}
+
+func (p *Perms) RunArgs(env *Environment, args *Args) error {
+	if env.Guild == nil {
+		return errors.New("No guild")
+	}
+	prefs, err := env.Bot.Driver.GuildPrefs(env.Guild.ID)
+	if err == ErrNotFound {
+		prefs = &GuildPrefs{GuildID: env.Guild.ID}
+	} else if err != nil {
+		return errors.New("couldn't lookup guild data 😦")
+	}
+
+	if !args.IsSet("command") {
+		if args.IsSet("allow") || args.IsSet("disallow") || args.Bool("reset") {
+			return errors.New("Which command?")
+		}
+		return env.Reply(describeRules(env, prefs.Permissions))
+	}
+	path, rest := resolve(env.Bot.commands, args.Strings("command"))
+	if len(path) == 0 || len(rest) > 0 {
+		return fmt.Errorf("I don't have a command %q", strings.Join(args.Strings("command"), " "))
+	}
+	name := pathName(path)
+
+	if !args.IsSet("allow") && !args.IsSet("disallow") && !args.Bool("reset") {
+		roles, ok := (&permissions{rules: prefs.Permissions}).rule(path)
+		return env.Reply(describeRule(env, name, roles, ok, path))
+	}
+	if !env.Bot.permissions(env).isAdmin {
+		return errors.New("Only members who can manage this guild can change who can run my commands")
+	}
+	for _, cmd := range path {
+		if cmd.IsOwnerOnly() {
+			return fmt.Errorf("Only my owner can run %v", name)
+		}
+		if cmd.IsAdminOnly() {
+			return fmt.Errorf("Only admins can run %v", name)
+		}
+	}
+
+	if prefs.Permissions == nil {
+		prefs.Permissions = make(map[string][]string)
+	}
+	roles := prefs.Permissions[name]
+	switch {
+	case args.IsSet("allow"):
+		roleID := args.String("allow")
+		for _, r := range roles {
+			if r == roleID {
+				return fmt.Errorf("That role can already run %v", name)
+			}
+		}
+		prefs.Permissions[name] = append(roles, roleID)
+	case args.IsSet("disallow"):
+		roleID := args.String("disallow")
+		kept := []string{}
+		for _, r := range roles {
+			if r != roleID {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == len(roles) {
+			return fmt.Errorf("That role isn't allowed to run %v", name)
+		}
+		prefs.Permissions[name] = kept
+		if len(kept) == 0 {
+			delete(prefs.Permissions, name)
+		}
+	default:
+		delete(prefs.Permissions, name)
+	}
+	return env.Bot.Driver.GuildPrefsSet(prefs)
+}
+
+func (p *Perms) Ack(env *Environment) string {
+	return "✅"
+}
+
+// describeRules lists the rules of a guild
+func describeRules(env *Environment, rules map[string][]string) string {
+	if len(rules) == 0 {
+		return "Anyone can run my commands in this guild, except the commands for admins."
+	}
+	names := []string{}
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf := &bytes.Buffer{}
+	buf.WriteString("Only these roles can run my commands in this guild:")
+	for _, name := range names {
+		fmt.Fprintf(buf, "\n`%s`: %s", name, roleNames(env, rules[name]))
+	}
+	return buf.String()
+}
+
+// describeRule explains who can run a command
+func describeRule(env *Environment, name string, roles []string, ok bool, path []Command) string {
+	for _, cmd := range path {
+		if cmd.IsOwnerOnly() {
+			return fmt.Sprintf("`%s` can only be run by my owner", name)
+		}
+		if cmd.IsAdminOnly() {
+			return fmt.Sprintf("`%s` can only be run by admins", name)
+		}
+	}
+	if ok {
+		return fmt.Sprintf("`%s` can be run by admins and %s", name, roleNames(env, roles))
+	}
+	return fmt.Sprintf("`%s` can be run by anyone", name)
+}
+
+// roleNames are the names of roles in the environment's guild
+func roleNames(env *Environment, roleIDs []string) string {
+	names := []string{}
+	for _, roleID := range roleIDs {
+		if role, err := env.Bot.State.Role(env.Guild.ID, roleID); err == nil {
+			names = append(names, role.Name)
+		} else {
+			names = append(names, roleID)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,213 @@
+package aoebot
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// a voicebox reads the clip it is playing every frame and connects to a voice channel in a few seconds,
+// so a queue that has not been read for this long has dropped the clip at its front
+const queueStallTimeout = 15 * time.Second
+
+// QueueItem is a clip said to a voice channel that is playing or waiting to play
+type QueueItem struct {
+	Title     string
+	ChannelID string
+	Playing   bool
+}
+
+// voiceQueues track the clips in the queue of each guild's voicebox
+// a voicebox does not expose its queue, so clips are tracked as they are enqueued and read
+// a voicebox does not say when it drops a clip either, e.g. when it can't connect or send,
+// so a clip is also forgotten when a later clip starts or when its queue stalls
+type voiceQueues struct {
+	mu     sync.Mutex
+	guilds map[string][]*QueueItem
+	// active is when the front of each guild's queue last made progress
+	active map[string]time.Time
+}
+
+func newVoiceQueues() *voiceQueues {
+	return &voiceQueues{
+		guilds: make(map[string][]*QueueItem),
+		active: make(map[string]time.Time),
+	}
+}
+
+// add tracks a clip and wraps its reader to notice when the clip starts and finishes playing
+func (q *voiceQueues) add(guildID string, item *QueueItem, r io.Reader) io.Reader {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune(guildID)
+	if len(q.guilds[guildID]) == 0 {
+		q.active[guildID] = time.Now()
+	}
+	q.guilds[guildID] = append(q.guilds[guildID], item)
+	return &queuedReader{Reader: r, guildID: guildID, item: item, queues: q}
+}
+
+// remove stops tracking a clip
+func (q *voiceQueues) remove(guildID string, item *QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.guilds[guildID]
+	for i, it := range items {
+		if it == item {
+			q.guilds[guildID] = append(items[:i:i], items[i+1:]...)
+			if i == 0 {
+				q.active[guildID] = time.Now()
+			}
+			return
+		}
+	}
+}
+
+// start marks a clip as playing
+// a voicebox plays clips in order, so the clips ahead of it were dropped
+func (q *voiceQueues) start(guildID string, item *QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item.Playing = true
+	items := q.guilds[guildID]
+	for i, it := range items {
+		if it == item {
+			q.guilds[guildID] = items[i:]
+			break
+		}
+	}
+	q.active[guildID] = time.Now()
+}
+
+// read notes that the voicebox is still reading the clips of a guild
+func (q *voiceQueues) read(guildID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.active[guildID] = time.Now()
+}
+
+// prune forgets the clips at the front of a guild's queue that the voicebox gave up on
+// prune must be called with the lock held
+func (q *voiceQueues) prune(guildID string) {
+	items := q.guilds[guildID]
+	active := q.active[guildID]
+	for len(items) > 0 && time.Since(active) > queueStallTimeout {
+		items = items[1:]
+		active = active.Add(queueStallTimeout)
+	}
+	q.guilds[guildID] = items
+	q.active[guildID] = active
+}
+
+// list copies the clips of a guild in the order they will play
+func (q *voiceQueues) list(guildID string) []QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune(guildID)
+	items := []QueueItem{}
+	for _, it := range q.guilds[guildID] {
+		items = append(items, *it)
+	}
+	return items
+}
+
+// removePlaying stops tracking the clip that is playing in a guild
+func (q *voiceQueues) removePlaying(guildID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := []*QueueItem{}
+	for _, it := range q.guilds[guildID] {
+		if !it.Playing {
+			kept = append(kept, it)
+		}
+	}
+	q.guilds[guildID] = kept
+	q.active[guildID] = time.Now()
+}
+
+// removeWaiting stops tracking the clips that have not started playing in a guild
+func (q *voiceQueues) removeWaiting(guildID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := []*QueueItem{}
+	for _, it := range q.guilds[guildID] {
+		if it.Playing {
+			kept = append(kept, it)
+		}
+	}
+	q.guilds[guildID] = kept
+	q.active[guildID] = time.Now()
+}
+
+// reset stops tracking every clip in a guild
+func (q *voiceQueues) reset(guildID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.guilds, guildID)
+	delete(q.active, guildID)
+}
+
+// queuedReader notices when the voicebox starts reading a clip and when it finishes
+type queuedReader struct {
+	io.Reader
+	guildID string
+	item    *QueueItem
+	queues  *voiceQueues
+	started bool
+}
+
+func (r *queuedReader) Read(p []byte) (n int, err error) {
+	if !r.started {
+		r.started = true
+		r.queues.start(r.guildID, r.item)
+	}
+	n, err = r.Reader.Read(p)
+	if err != nil {
+		r.queues.remove(r.guildID, r.item)
+	} else {
+		r.queues.read(r.guildID)
+	}
+	return
+}
+
+// Queue lists the clips that are playing or waiting to play in a guild
+func (b *Bot) Queue(guildID string) []QueueItem {
+	return b.queues.list(guildID)
+}
+
+// Skip stops the clip that is playing in a guild so the next clip plays
+func (b *Bot) Skip(guildID string) error {
+	player, ok := b.voiceboxes[guildID]
+	if !ok || player == nil {
+		return errors.New("No voicebox registered for this guild")
+	}
+	if err := player.Skip(); err != nil {
+		return err
+	}
+	b.queues.removePlaying(guildID)
+	return nil
+}
+
+// ClearQueue removes the clips that are waiting to play in a guild
+func (b *Bot) ClearQueue(guildID string) error {
+	player, ok := b.voiceboxes[guildID]
+	if !ok || player == nil {
+		return errors.New("No voicebox registered for this guild")
+	}
+	if err := player.Clear(); err != nil {
+		return err
+	}
+	b.queues.removeWaiting(guildID)
+	return nil
+}
+
+// StopVoice removes the clips that are waiting to play in a guild and stops the clip that is playing
+func (b *Bot) StopVoice(guildID string) error {
+	// the voicebox drops whatever it was about to play too
+	defer b.queues.reset(guildID)
+	if err := b.ClearQueue(guildID); err != nil {
+		return err
+	}
+	return b.Skip(guildID)
+}
@@ -0,0 +1,417 @@
+package aoebot
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ArgType is the type of the value of a command's flag or positional argument
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	// ArgPhrase is a string that is usually quoted because it has spaces
+	ArgPhrase
+	ArgInt
+	ArgDuration
+	ArgBool
+	ArgEmoji
+	ArgUser
+	ArgRole
+	ArgChannel
+	// ArgLiteral is a positional argument that must be its own name, e.g. the "on" in addwrite
+	ArgLiteral
+)
+
+// placeholder is how a value of the type is written in a usage line
+func (t ArgType) placeholder() string {
+	switch t {
+	case ArgInt:
+		return "n"
+	case ArgDuration:
+		return "duration"
+	case ArgEmoji:
+		return "emoji"
+	case ArgUser:
+		return "@user"
+	case ArgRole:
+		return "@role"
+	case ArgChannel:
+		return "#channel"
+	}
+	return "value"
+}
+
+// Flag declares a flag of a command
+type Flag struct {
+	Name  string
+	Type  ArgType
+	Usage string
+	// Value is how the flag's value is written in the usage line, e.g. the "percent" in [-chance percent]
+	Value string
+	// Default is parsed the same way as input when the flag is not used
+	Default string
+	// Choices restricts the value of a string flag, if it is not empty
+	Choices []string
+}
+
+// Arg declares a positional argument of a command
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+	// Variadic consumes the rest of the arguments, it should be the last argument
+	Variadic bool
+}
+
+// Schema declares the flags and positional arguments of a command
+// Bot parses a command's input against its schema before running it,
+// and the schema generates the command's usage line
+type Schema struct {
+	Flags []Flag
+	Args  []Arg
+	// Exclusive are groups of flags that can not be used together
+	Exclusive [][]string
+}
+
+// Args are the values of the flags and positional arguments of a command
+// Values are the zero value for their type when they were not given and have no default
+type Args struct {
+	values map[string]interface{}
+	set    map[string]bool
+}
+
+func (a *Args) String(name string) string {
+	s, _ := a.values[name].(string)
+	return s
+}
+
+// Strings are the values of a variadic argument
+func (a *Args) Strings(name string) []string {
+	s, _ := a.values[name].([]string)
+	return s
+}
+
+func (a *Args) Int(name string) int {
+	n, _ := a.values[name].(int)
+	return n
+}
+
+func (a *Args) Duration(name string) time.Duration {
+	d, _ := a.values[name].(time.Duration)
+	return d
+}
+
+func (a *Args) Bool(name string) bool {
+	b, _ := a.values[name].(bool)
+	return b
+}
+
+// IsSet is true when a flag or argument was given
+func (a *Args) IsSet(name string) bool {
+	return a.set[name]
+}
+
+// ArgsCommand is a Command that declares its flags and positional arguments with a Schema
+// Bot calls RunArgs instead of Run with the parsed and validated values
+type ArgsCommand interface {
+	Command
+	Schema() Schema
+	RunArgs(env *Environment, args *Args) error
+}
+
+// Usage is a command's usage line
+// The usage line of an ArgsCommand is generated from its Schema
+func Usage(cmd Command) string {
+	if ac, ok := cmd.(ArgsCommand); ok {
+		return strings.TrimSpace(cmd.Name() + " " + ac.Schema().Usage())
+	}
+	return cmd.Usage()
+}
+
+// Usage writes the flags and positional arguments of a schema,
+// e.g. [-regex | -match mode] [-chance percent] "[response]" on "[phrase]"
+func (s Schema) Usage() string {
+	parts := []string{}
+	written := make(map[string]bool)
+	for _, f := range s.Flags {
+		if written[f.Name] {
+			continue
+		}
+		group := []string{f.flagUsage()}
+		for _, name := range s.exclusiveWith(f.Name) {
+			if other, ok := s.flag(name); ok && !written[name] {
+				group = append(group, other.flagUsage())
+				written[name] = true
+			}
+		}
+		written[f.Name] = true
+		parts = append(parts, "["+strings.Join(group, " | ")+"]")
+	}
+	for _, a := range s.Args {
+		parts = append(parts, a.argUsage())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f Flag) flagUsage() string {
+	if f.Type == ArgBool {
+		return "-" + f.Name
+	}
+	value := f.Value
+	if value == "" {
+		value = f.Type.placeholder()
+	}
+	return "-" + f.Name + " " + value
+}
+
+func (a Arg) argUsage() string {
+	if a.Type == ArgLiteral {
+		return a.Name
+	}
+	usage := "[" + a.Name + "]"
+	if a.Type == ArgPhrase {
+		usage = `"` + usage + `"`
+	}
+	if a.Variadic {
+		usage += "..."
+	}
+	return usage
+}
+
+func (s Schema) flag(name string) (Flag, bool) {
+	for _, f := range s.Flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// exclusiveWith is the flags that can not be used with a flag
+func (s Schema) exclusiveWith(name string) []string {
+	others := []string{}
+	for _, group := range s.Exclusive {
+		for _, n := range group {
+			if n == name {
+				for _, other := range group {
+					if other != name {
+						others = append(others, other)
+					}
+				}
+			}
+		}
+	}
+	return others
+}
+
+// flagValue records the raw value of a flag so it can be validated after the flags are parsed
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	return v.raw
+}
+
+func (v *flagValue) Set(s string) error {
+	v.raw = s
+	return nil
+}
+
+// IsBoolFlag lets a bool flag be used without a value
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// Parse validates a command's input against a schema
+// User, role, and channel arguments are checked against the environment's guild
+func (s Schema) Parse(env *Environment, input []string) (*Args, error) {
+	args := &Args{
+		values: make(map[string]interface{}),
+		set:    make(map[string]bool),
+	}
+
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	raw := make(map[string]*flagValue)
+	for _, f := range s.Flags {
+		v := &flagValue{isBool: f.Type == ArgBool}
+		fs.Var(v, f.Name, f.Usage)
+		raw[f.Name] = v
+	}
+	if err := fs.Parse(input); err != nil {
+		return nil, err
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		args.set[fl.Name] = true
+	})
+	for _, group := range s.Exclusive {
+		used := []string{}
+		for _, name := range group {
+			if args.set[name] {
+				used = append(used, "-"+name)
+			}
+		}
+		if len(used) > 1 {
+			return nil, fmt.Errorf("Use only one of %v", strings.Join(used, ", "))
+		}
+	}
+	for _, f := range s.Flags {
+		value := f.Default
+		if args.set[f.Name] {
+			value = raw[f.Name].raw
+		} else if value == "" {
+			args.values[f.Name] = zero(f.Type)
+			continue
+		}
+		v, err := parseValue(env, "-"+f.Name, f.Type, f.Choices, value)
+		if err != nil {
+			return nil, err
+		}
+		args.values[f.Name] = v
+	}
+
+	rest := fs.Args()
+	for _, a := range s.Args {
+		if len(rest) == 0 {
+			if !a.Optional {
+				return nil, fmt.Errorf("Missing %v", a.argUsage())
+			}
+			args.values[a.Name] = zero(a.Type)
+			continue
+		}
+		if a.Variadic {
+			values := []string{}
+			for _, r := range rest {
+				v, err := parseValue(env, a.Name, a.Type, nil, r)
+				if err != nil {
+					return nil, err
+				}
+				if str, ok := v.(string); ok {
+					values = append(values, str)
+				}
+			}
+			args.values[a.Name] = values
+			args.set[a.Name] = true
+			rest = nil
+			continue
+		}
+		v, err := parseValue(env, a.Name, a.Type, nil, rest[0])
+		if err != nil {
+			return nil, err
+		}
+		args.values[a.Name] = v
+		args.set[a.Name] = true
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("Too many arguments, did you forget quotes?")
+	}
+	return args, nil
+}
+
+func zero(t ArgType) interface{} {
+	switch t {
+	case ArgInt:
+		return 0
+	case ArgDuration:
+		return time.Duration(0)
+	case ArgBool:
+		return false
+	}
+	return ""
+}
+
+var (
+	customEmojiRegexp = regexp.MustCompile(`^<a?:(\S+:\S+)>$`)
+	userRegexp        = regexp.MustCompile(`^<@!?(\d+)>$`)
+	roleRegexp        = regexp.MustCompile(`^<@&(\d+)>$`)
+	channelRegexp     = regexp.MustCompile(`^<#(\d+)>$`)
+)
+
+// parseValue validates and converts a raw value of a flag or argument
+// emoji are converted to the form used by ReactAction.Emoji and mentions are converted to IDs
+func parseValue(env *Environment, name string, t ArgType, choices []string, raw string) (interface{}, error) {
+	switch t {
+	case ArgInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%v should be a whole number, not %q", name, raw)
+		}
+		return n, nil
+	case ArgDuration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%v should be a duration like 30s or 5m, not %q", name, raw)
+		}
+		return d, nil
+	case ArgBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%v should be true or false, not %q", name, raw)
+		}
+		return b, nil
+	case ArgEmoji:
+		if submatches := customEmojiRegexp.FindStringSubmatch(raw); submatches != nil {
+			return submatches[1], nil
+		}
+		if raw == "" || strings.IndexFunc(raw, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("%v should be an emoji, not %q", name, raw)
+		}
+		return raw, nil
+	case ArgUser:
+		id := mentionID(userRegexp, raw)
+		if env.Guild != nil {
+			if _, err := env.Bot.State.Member(env.Guild.ID, id); err != nil {
+				return nil, fmt.Errorf("%v should mention someone in this guild, not %q", name, raw)
+			}
+		}
+		return id, nil
+	case ArgRole:
+		id := mentionID(roleRegexp, raw)
+		if env.Guild != nil {
+			if _, err := env.Bot.State.Role(env.Guild.ID, id); err != nil {
+				return nil, fmt.Errorf("%v should mention a role in this guild, not %q", name, raw)
+			}
+		}
+		return id, nil
+	case ArgChannel:
+		id := mentionID(channelRegexp, raw)
+		ch, err := env.Bot.State.Channel(id)
+		if err != nil || (env.Guild != nil && ch.GuildID != env.Guild.ID) {
+			return nil, fmt.Errorf("%v should mention a channel in this guild, not %q", name, raw)
+		}
+		return id, nil
+	case ArgLiteral:
+		if !strings.EqualFold(raw, name) {
+			return nil, fmt.Errorf("Expected %q, not %q", name, raw)
+		}
+		return raw, nil
+	}
+	if len(choices) > 0 {
+		for _, choice := range choices {
+			if strings.EqualFold(raw, choice) {
+				return choice, nil
+			}
+		}
+		return nil, fmt.Errorf("%v should be one of %v, not %q", name, strings.Join(choices, ", "), raw)
+	}
+	return raw, nil
+}
+
+// mentionID is the ID in a mention, or raw if it is not a mention
+func mentionID(mention *regexp.Regexp, raw string) string {
+	if submatches := mention.FindStringSubmatch(raw); submatches != nil {
+		return submatches[1]
+	}
+	return raw
+}
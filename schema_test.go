@@ -0,0 +1,97 @@
+package aoebot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSchema = Schema{
+	Flags: []Flag{
+		{Name: "tts", Type: ArgBool},
+		{Name: "regex", Type: ArgBool},
+		{Name: "cooldown", Type: ArgDuration},
+		{Name: "chance", Type: ArgInt, Default: "100"},
+		{Name: "per", Choices: []string{"user", "channel"}},
+		{Name: "reaction", Type: ArgEmoji},
+		{Name: "user", Type: ArgUser},
+	},
+	Args: []Arg{
+		{Name: "response", Type: ArgPhrase},
+		{Name: "on", Type: ArgLiteral},
+		{Name: "phrase", Type: ArgPhrase, Optional: true},
+	},
+	Exclusive: [][]string{{"tts", "regex"}},
+}
+
+func TestSchemaParse(t *testing.T) {
+	args, err := testSchema.Parse(&Environment{}, []string{"-tts", "-cooldown", "1m", "-per", "User", "-reaction", "<:aoe:123>", "-user", "<@!456>", "pong", "ON", "ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !args.Bool("tts") || args.Bool("regex") {
+		t.Errorf("tts %v regex %v", args.Bool("tts"), args.Bool("regex"))
+	}
+	if args.Duration("cooldown") != time.Minute {
+		t.Errorf("cooldown %v", args.Duration("cooldown"))
+	}
+	if args.Int("chance") != 100 || args.IsSet("chance") {
+		t.Errorf("chance %v should be the default", args.Int("chance"))
+	}
+	if args.String("per") != "user" {
+		t.Errorf("per %q", args.String("per"))
+	}
+	if args.String("reaction") != "aoe:123" {
+		t.Errorf("reaction %q", args.String("reaction"))
+	}
+	if args.String("user") != "456" {
+		t.Errorf("user %q", args.String("user"))
+	}
+	if args.String("response") != "pong" || args.String("phrase") != "ping" {
+		t.Errorf("response %q phrase %q", args.String("response"), args.String("phrase"))
+	}
+}
+
+func TestSchemaParseOptional(t *testing.T) {
+	args, err := testSchema.Parse(&Environment{}, []string{"pong", "on"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.IsSet("phrase") || args.String("phrase") != "" {
+		t.Errorf("phrase %q should not be set", args.String("phrase"))
+	}
+}
+
+func TestSchemaParseVariadic(t *testing.T) {
+	schema := Schema{Args: []Arg{{Name: "command", Optional: true, Variadic: true}}}
+	args, err := schema.Parse(&Environment{}, []string{"meme", "add", "write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(args.Strings("command"), " "); got != "meme add write" {
+		t.Errorf("command %q", got)
+	}
+}
+
+func TestSchemaParseErrors(t *testing.T) {
+	tests := []struct {
+		input []string
+		err   string
+	}{
+		{[]string{"-tts", "-regex", "pong", "on"}, "Use only one of -tts, -regex"},
+		{[]string{"-cooldown", "soon", "pong", "on"}, "-cooldown should be a duration"},
+		{[]string{"-chance", "half", "pong", "on"}, "-chance should be a whole number"},
+		{[]string{"-per", "guild", "pong", "on"}, "-per should be one of user, channel"},
+		{[]string{"-reaction", "two words", "pong", "on"}, "-reaction should be an emoji"},
+		{[]string{"-nope", "pong", "on"}, "flag provided but not defined"},
+		{[]string{"pong"}, "Missing"},
+		{[]string{"pong", "in", "ping"}, `Expected "on"`},
+		{[]string{"pong", "on", "ping", "pang"}, "Too many arguments"},
+	}
+	for _, tt := range tests {
+		_, err := testSchema.Parse(&Environment{}, tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) = %v, want %q", tt.input, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package aoebot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session is the part of a discord session that the bot depends on
+// *discordgo.Session implements Session
+// Cached guilds, channels, members, roles, and messages are read from the bot's State instead
+type Session interface {
+	Open() error
+	Close() error
+	AddHandler(handler interface{}) func()
+	AddHandlerOnce(handler interface{}) func()
+	UpdateStatus(idle int, game string) error
+	RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) ([]byte, error)
+
+	User(userID string) (*discordgo.User, error)
+	UserChannelCreate(recipientID string) (*discordgo.Channel, error)
+
+	GuildMemberRoleAdd(guildID, userID, roleID string) error
+	GuildMemberRoleRemove(guildID, userID, roleID string) error
+	GuildChannelCreate(guildID, name string, ctype string) (*discordgo.Channel, error)
+
+	ChannelDelete(channelID string) (*discordgo.Channel, error)
+	ChannelTyping(channelID string) error
+	ChannelPermissionSet(channelID, targetID, targetType string, allow, deny int) error
+	ChannelMessage(channelID, messageID string) (*discordgo.Message, error)
+	ChannelMessageSend(channelID string, content string) (*discordgo.Message, error)
+	ChannelMessageSendTTS(channelID string, content string) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+
+	MessageReactionAdd(channelID, messageID, emojiID string) error
+	MessageReactionRemove(channelID, messageID, emojiID, userID string) error
+}
+
+var _ Session = &discordgo.Session{}
@@ -0,0 +1,74 @@
+package aoebot
+
+import (
+	"errors"
+)
+
+// ErrNotFound is returned by a Store when a requested document does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Store is a storage backend for the bot.
+// Store persists conditions, managed channels, guild preferences, game aliases, and the records of uploaded clips.
+type Store interface {
+	// ConditionsEnabled retrieves every enabled condition.
+	ConditionsEnabled() ([]Condition, error)
+	// ConditionsEnvironment retrieves the enabled conditions whose requirements may be met by an environment.
+	// Conditions with a regex phrase are not tested against the environment.
+	ConditionsEnvironment(env *Environment) []Condition
+	// ConditionsGuild retrieves the enabled custom conditions for a guild.
+	ConditionsGuild(guildID string) []Condition
+	// ConditionsTagged retrieves the conditions that have a tag.
+	ConditionsTagged(tag string) []Condition
+	// ConditionAdd inserts or enables a custom condition.
+	ConditionAdd(c *Condition, creator string) error
+	// ConditionDisable disables a condition and any of its duplicates.
+	ConditionDisable(c *Condition) error
+	// ConditionsSwapClip makes the enabled custom conditions of a guild that say a clip say another clip instead.
+	ConditionsSwapClip(guildID string, oldID string, newID string) error
+
+	Channels() []ManagedChannel
+	ChannelsGuild(guildID string) []ManagedChannel
+	ChannelAdd(ch ManagedChannel) error
+	ChannelDelete(channelID ...string) error
+
+	// GuildPrefs returns ErrNotFound when a guild has no saved preferences.
+	GuildPrefs(guildID string) (*GuildPrefs, error)
+	GuildPrefsSet(prefs *GuildPrefs) error
+
+	// GameByAlias returns the empty string when alias is not registered to any game.
+	GameByAlias(alias string) string
+	// GameAliasAdd overwrites an existing entry for the same alias.
+	GameAliasAdd(ga GameAlias) error
+	// Games retrieves the unique names of all games in sorted order.
+	Games() []string
+
+	// Clip returns ErrNotFound when no clip has the id.
+	Clip(id string) (*Clip, error)
+	Clips() []Clip
+	// ClipAdd keeps the existing record when a clip with the same id was already added.
+	ClipAdd(clip *Clip) error
+	ClipDelete(clipID ...string) error
+
+	Close()
+}
+
+// StoreDialer opens a Store.
+// The bot dials its Store every time it starts.
+type StoreDialer func() (Store, error)
+
+// GuildPrefs are the settings saved for a guild.
+type GuildPrefs struct {
+	GuildID       string            `bson:"guild"`
+	SpamChannelID string            `bson:"spam_channel,omitempty"`
+	GameRoles     map[string]string `bson:"game_roles,omitempty"`
+	// Prefix replaces the bot's default command prefix in the guild when it is not empty
+	Prefix string `bson:"prefix,omitempty"`
+	// Permissions are the roles that may run a command or group of commands, keyed by its full name, e.g. meme add
+	Permissions map[string][]string `bson:"permissions,omitempty"`
+}
+
+// GameAlias registers a nickname for a game.
+type GameAlias struct {
+	Game  string
+	Alias string
+}
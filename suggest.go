@@ -0,0 +1,125 @@
+package aoebot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// a name is only suggested for a typo when it is this many edits away or closer
+const maxSuggestDistance = 2
+
+// only suggest this many names for a typo
+const maxSuggestions = 3
+
+// suggestion is a command with a name or alias that is close to a typo
+type suggestion struct {
+	path     []Command
+	distance int
+}
+
+// suggest finds the commands with a name or alias that is close to a typo
+// suggest also considers the aliases of subcommands nested in groups, like resolve
+func suggest(commands []Command, parent []Command, typo string) []suggestion {
+	typo = strings.ToLower(typo)
+	closest := make(map[Command]*suggestion)
+	order := []Command{}
+	var consider func(commands []Command, parent []Command, aliasesOnly bool)
+	consider = func(commands []Command, parent []Command, aliasesOnly bool) {
+		for _, cmd := range commands {
+			if cmd.IsOwnerOnly() {
+				continue
+			}
+			path := append(append([]Command{}, parent...), cmd)
+			names := cmd.Aliases()
+			if !aliasesOnly {
+				names = append([]string{cmd.Name()}, names...)
+			}
+			for _, name := range names {
+				d := editDistance(typo, strings.ToLower(name))
+				if d > maxSuggestDistance || d >= len(typo) {
+					continue
+				}
+				if s, ok := closest[cmd]; !ok {
+					closest[cmd] = &suggestion{path, d}
+					order = append(order, cmd)
+				} else if d < s.distance {
+					s.distance = d
+				}
+			}
+			if g, ok := cmd.(*Group); ok {
+				consider(g.Subcommands, path, true)
+			}
+		}
+	}
+	consider(commands, parent, false)
+
+	suggestions := []suggestion{}
+	for _, cmd := range order {
+		suggestions = append(suggestions, *closest[cmd])
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
+
+// unknownCommand explains that a name is not one of the commands, suggesting close names
+// and how to use the closest command
+func unknownCommand(env *Environment, commands []Command, parent []Command, typo string) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "I don't know `%s`.", strings.TrimSpace(pathName(parent)+" "+typo))
+	suggestions := suggest(commands, parent, typo)
+	if len(suggestions) == 0 {
+		fmt.Fprintf(buf, "  Try %s %s", env.Prefix(), (&Help{}).Name())
+		return errors.New(buf.String())
+	}
+	names := []string{}
+	for _, s := range suggestions {
+		names = append(names, "`"+pathName(s.path)+"`")
+	}
+	fmt.Fprintf(buf, "  Did you mean %s?", strings.Join(names, " or "))
+	fmt.Fprintf(buf, "\nUsage: %s %s", env.Prefix(), pathUsage(suggestions[0].path))
+	return errors.New(buf.String())
+}
+
+// editDistance counts the insertions, deletions, substitutions, and swaps of adjacent letters that turn a into b
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// d[i][j] is the distance between the first i letters of s and the first j letters of t
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func minInt(n int, ns ...int) int {
+	for _, m := range ns {
+		if m < n {
+			n = m
+		}
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package aoebot
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateData is the view of an environment that a WriteAction template can see
+// It exposes names and mentions rather than the underlying discordgo types so
+// that a template cannot reach the bot or the session
+type templateData struct {
+	Author   templateUser
+	Channel  templateNamed
+	Guild    templateNamed
+	Message  string
+	Captures []string
+	Now      time.Time
+}
+
+type templateUser struct {
+	ID       string
+	Username string
+	Mention  string
+}
+
+type templateNamed struct {
+	ID   string
+	Name string
+}
+
+// Random is a random number in [0, n)
+func (templateData) Random(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return rand.Intn(n)
+}
+
+func newTemplateData(env *Environment) templateData {
+	data := templateData{
+		Captures: env.Captures,
+		Now:      time.Now(),
+	}
+	if env.Author != nil {
+		data.Author = templateUser{
+			ID:       env.Author.ID,
+			Username: env.Author.Username,
+			Mention:  env.Author.Mention(),
+		}
+	}
+	if env.TextChannel != nil {
+		data.Channel = templateNamed{env.TextChannel.ID, env.TextChannel.Name}
+	}
+	if env.Guild != nil {
+		data.Guild = templateNamed{env.Guild.ID, env.Guild.Name}
+	}
+	if env.TextMessage != nil {
+		data.Message = env.TextMessage.Content
+	}
+	return data
+}
+
+// isTemplate is true when content has template actions
+func isTemplate(content string) bool {
+	return strings.Contains(content, "{{")
+}
+
+// ParseTemplate parses the content of a WriteAction as a text/template
+// Templates can refer to .Author.Mention, .Author.Username, .Channel.Name, .Guild.Name,
+// .Message, .Captures (groups captured by the condition's regex phrase), .Now, and .Random n
+func (wa WriteAction) ParseTemplate() (*template.Template, error) {
+	return template.New("write").Parse(wa.Content)
+}
+
+// render executes the content of a WriteAction against an environment
+func (wa WriteAction) render(env *Environment) (string, error) {
+	if !isTemplate(wa.Content) {
+		return wa.Content, nil
+	}
+	tmpl, err := wa.ParseTemplate()
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, newTemplateData(env)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,77 @@
+package aoebot
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits a line of input into arguments the way a shell would.
+// Arguments are separated by whitespace, unless the whitespace is quoted or escaped.
+// A backslash only escapes a quote, another backslash, or whitespace outside of quotes,
+// so regular expressions like "(\w+)er$" can be written without doubling backslashes.
+// Nothing is escaped inside single quotes.
+// A single quote only starts a quote at the start of an argument, so a word like don't doesn't need escaping.
+func Tokenize(line string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	// inArg is true once anything, even an empty pair of quotes, has started an argument
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			if !escapable(r, quote) {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || (r == '\'' && !inArg):
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("Unterminated quote")
+	}
+	if escaped {
+		arg.WriteRune('\\')
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// escapable is true if a backslash escapes r instead of standing for itself
+func escapable(r rune, quote rune) bool {
+	if r == '"' || r == '\\' {
+		return true
+	}
+	return quote == 0 && (r == '\'' || unicode.IsSpace(r))
+}
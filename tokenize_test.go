@@ -0,0 +1,44 @@
+package aoebot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line string
+		args []string
+	}{
+		{`meme add write "pong" on "ping"`, []string{"meme", "add", "write", "pong", "on", "ping"}},
+		{`  a   b  `, []string{"a", "b"}},
+		{`"two  spaces" x`, []string{"two  spaces", "x"}},
+		{`"say \"hi\""`, []string{`say "hi"`}},
+		{`"\\"`, []string{`\`}},
+		{`"(\w+)er$"`, []string{`(\w+)er$`}},
+		{`'a "b" \c'`, []string{`a "b" \c`}},
+		{`a\ b ""`, []string{"a b", ""}},
+		{`don't`, []string{"don't"}},
+		{`play nice town i'll take it`, []string{"play", "nice", "town", "i'll", "take", "it"}},
+		{`'it's'`, []string{"its'"}},
+		{`\'quoted\'`, []string{"'quoted'"}},
+		{`meme add react -regex 👀 on ^\w+$`, []string{"meme", "add", "react", "-regex", "👀", "on", `^\w+$`}},
+		{`trailing\`, []string{`trailing\`}},
+	}
+	for _, tt := range tests {
+		args, err := Tokenize(tt.line)
+		if err != nil {
+			t.Errorf("Tokenize(%q) = %v", tt.line, err)
+		} else if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.line, args, tt.args)
+		}
+	}
+}
+
+func TestTokenizeUnterminatedQuote(t *testing.T) {
+	for _, line := range []string{`"oops`, `'oops`, `a "b`} {
+		if _, err := Tokenize(line); err == nil {
+			t.Errorf("Tokenize(%q) should fail", line)
+		}
+	}
+}
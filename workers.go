@@ -0,0 +1,144 @@
+package aoebot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// each worker has room for this many commands to wait for it
+const commandQueuePerWorker = 4
+
+// wait this long before showing that the bot is typing while a command runs
+const typingDelay = 1 * time.Second
+
+// discord shows that the bot is typing for about 10 seconds
+const typingInterval = 8 * time.Second
+
+// job is a command waiting to be run by a worker
+// ctx is done when the bot stops
+type job func(ctx context.Context)
+
+// startWorkers starts a bounded number of goroutines that run commands, so slow commands do not block event handlers
+// the workers quit when ctx is done
+func (b *Bot) startWorkers(ctx context.Context) {
+	n := b.Config.CommandWorkers
+	if n < 1 {
+		n = DefaultConfig.CommandWorkers
+	}
+	b.jobs = make(chan job, n*commandQueuePerWorker)
+	for i := 0; i < n; i++ {
+		go work(ctx, b.jobs)
+	}
+}
+
+func work(ctx context.Context, jobs <-chan job) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-jobs:
+			j(ctx)
+		}
+	}
+}
+
+// enqueue is false when every worker is busy and the queue is full, or the bot has not started
+func (b *Bot) enqueue(j job) bool {
+	b.mu.Lock()
+	jobs := b.jobs
+	b.mu.Unlock()
+	if jobs == nil {
+		return false
+	}
+	b.pending.Add(1)
+	select {
+	case jobs <- func(ctx context.Context) {
+		defer b.pending.Done()
+		j(ctx)
+	}:
+		return true
+	default:
+		b.pending.Done()
+		return false
+	}
+}
+
+// Wait blocks until every command that has been queued and every action that has been dispatched is finished
+// Wait should not be used after the bot stops, since commands still in the queue are never run
+func (b *Bot) Wait() {
+	b.pending.Wait()
+}
+
+// timeout is how long a command may run
+func (b *Bot) timeout(cmd Command) time.Duration {
+	if t := cmd.Timeout(); t > 0 {
+		return t
+	}
+	if b.Config.CommandTimeout > 0 {
+		return time.Duration(b.Config.CommandTimeout) * time.Second
+	}
+	return time.Duration(DefaultConfig.CommandTimeout) * time.Second
+}
+
+// execute runs a command with a context that is done when the command times out or the bot stops
+// the command gets its own copy of env, so the context it cancels stays with the command
+func (b *Bot) execute(ctx context.Context, env *Environment, path []Command, args []string) {
+	cmd := path[len(path)-1]
+	timeout := b.timeout(cmd)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmdEnv := *env
+	cmdEnv.Context = ctx
+	env = &cmdEnv
+
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("Recovered from panic in exec %v with %v: %v", pathName(path), args, err)
+		}
+	}()
+	// a slash command already shows that the bot is thinking
+	if env.Interaction == nil {
+		go b.typing(ctx, env.TextChannel.ID)
+	}
+
+	err := run(env, path, args)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("That took longer than %v, so I gave up", timeout)
+	}
+	// stop typing before responding
+	cancel()
+	if err != nil {
+		log.Printf("Error in exec %v with %v: %v", pathName(path), args, err)
+		env.Reply(fmt.Sprintf("🤔...\n%v", err))
+	} else if env.Interaction != nil {
+		// a slash command needs a reply to stop thinking
+		if !env.Interaction.hasReplied() {
+			env.Reply(ackOrDefault(cmd.Ack(env)))
+		}
+	} else if cmd.Ack(env) != "" {
+		b.React(env.TextChannel.ID, env.TextMessage.ID, cmd.Ack(env))
+	}
+}
+
+// typing shows that the bot is typing in a channel while a command runs for longer than a moment
+func (b *Bot) typing(ctx context.Context, channelID string) {
+	delay := time.NewTimer(typingDelay)
+	defer delay.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-delay.C:
+	}
+	ticker := time.NewTicker(typingInterval)
+	defer ticker.Stop()
+	for {
+		b.Session.ChannelTyping(channelID)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}